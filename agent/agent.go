@@ -1,11 +1,16 @@
 package agent
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"os"
+	"strings"
 
 	"go-tui/agent/tools"
 	"go-tui/llm"
 	"go-tui/lsp"
+	"go-tui/sandbox"
 )
 
 const systemPromptTemplate = `You are an expert coding assistant with integrated LSP support. You help users write, debug, and improve code.
@@ -28,17 +33,45 @@ Rules:
 
 type Agent struct {
 	workingDir string
+	tools      []llm.Tool
+	enabled    map[string]bool
 }
 
+// New creates an agent rooted at workingDir. If LLM_TOOLS is set to a
+// comma-separated list of tool names, only those tools are offered to the
+// model, which keeps the prompt small for local models with short contexts.
 func New(workingDir string) *Agent {
 	lsp.Start(workingDir)
-	return &Agent{
+	a := &Agent{
 		workingDir: workingDir,
+		tools:      tools.All(),
 	}
+	if list := os.Getenv("LLM_TOOLS"); list != "" {
+		var names []string
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if selected, err := tools.Select(names); err != nil {
+			log.Printf("ignoring LLM_TOOLS: %v", err)
+		} else {
+			a.tools = selected
+		}
+	}
+	a.enabled = make(map[string]bool, len(a.tools))
+	for _, t := range a.tools {
+		a.enabled[t.Function.Name] = true
+	}
+	return a
 }
 
 func (a *Agent) Shutdown() {
 	lsp.Stop()
+	tools.ResetShell()
+	tools.KillProcesses()
+	tools.RemoveSpillFiles()
+	sandbox.Cleanup()
 }
 
 func (a *Agent) WorkingDir() string {
@@ -49,10 +82,20 @@ func (a *Agent) SystemPrompt() string {
 	return fmt.Sprintf(systemPromptTemplate, a.workingDir)
 }
 
-func (a *Agent) ExecuteTool(name, argsJSON string) (tools.ToolResult, error) {
-	return tools.Execute(name, argsJSON, a.workingDir)
+func (a *Agent) ExecuteTool(ctx context.Context, name, argsJSON string) (tools.ToolResult, error) {
+	if !a.enabled[name] {
+		return tools.ToolResult{}, fmt.Errorf("tool not available: %s", name)
+	}
+	return tools.Execute(ctx, name, argsJSON, a.workingDir)
+}
+
+// IsReadOnly reports whether the named tool only inspects state, so calls to
+// it can run concurrently.
+func (a *Agent) IsReadOnly(name string) bool {
+	return a.enabled[name] && tools.IsReadOnly(name)
 }
 
+// Tools returns the schemas of the tools offered to the model.
 func (a *Agent) Tools() []llm.Tool {
-	return tools.All()
+	return a.tools
 }
@@ -0,0 +1,396 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go-tui/config"
+	"go-tui/lsp"
+)
+
+type ApplyPatchArgs struct {
+	Patch string `json:"patch"`
+}
+
+// PatchedFile tells the model what a patch did to one file and where. The
+// changed text itself is only shown to the user.
+type PatchedFile struct {
+	FilePath     string      `json:"file_path"`
+	Action       string      `json:"action"` // "created", "modified", "deleted", "renamed" or "unchanged"
+	MovedFrom    string      `json:"moved_from,omitempty"`
+	Hunks        int         `json:"hunks"`
+	ChangedLines []LineRange `json:"changed_lines,omitempty"`
+}
+
+type ApplyPatchResult struct {
+	Files       []PatchedFile `json:"files"`
+	LSPFeedback string        `json:"lsp_feedback,omitempty"`
+}
+
+func init() {
+	Register(Typed[ApplyPatchArgs]{
+		ToolName:        "apply_patch",
+		ToolDescription: "Apply a unified diff, as produced by git diff or diff -u, to one or more files. Use --- /dev/null to create a file and +++ /dev/null to delete one. Hunks are located by their context lines, so line numbers in @@ headers may be approximate or left out, and small whitespace differences are tolerated. The patch is applied to every file or to none. For changes to a single file prefer edit_file or multi_edit. NOTE: After patching, the system runs LSP diagnostics on the changed files and provides feedback in the result.",
+		ToolSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"patch": {
+					"type": "string",
+					"description": "The unified diff, with ---/+++ file headers (paths relative to the working directory, optionally with git's a/ and b/ prefixes) and @@ hunks"
+				}
+			},
+			"required": ["patch"]
+		}`),
+		ToolInfo: Info{
+			Category:    CategoryEdit,
+			Icon:        config.PatchIcon,
+			Destructive: true,
+			Permission:  PermissionAsk,
+			RuleKind:    RulePath,
+		},
+		ToolFormat:  formatApplyPatch,
+		ToolChanges: applyPatchChanges,
+		ToolPaths:   applyPatchPaths,
+		Run:         executeApplyPatch,
+	})
+}
+
+// patchTarget is a file a patch touches, with its content before and after.
+type patchTarget struct {
+	path      string // as written in the patch
+	abs       string
+	before    string
+	existed   bool
+	after     string
+	exists    bool // whether the file is there once the patch is applied
+	movedFrom string
+	hunks     int // hunks of the patch applied to the file
+}
+
+func executeApplyPatch(ctx context.Context, args ApplyPatchArgs, workingDir string) (ToolResult, error) {
+	if strings.TrimSpace(args.Patch) == "" {
+		return ToolResult{}, NewToolError(ErrMissingField, "patch is required")
+	}
+	targets, err := planPatch(args.Patch, workingDir)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	if err := commitPatch(targets); err != nil {
+		return ToolResult{}, err
+	}
+
+	result := ApplyPatchResult{Files: patchedFiles(targets)}
+	if lsp.DefaultManager != nil {
+		var feedback []string
+		for _, t := range targets {
+			if !t.exists || t.after == t.before {
+				continue
+			}
+			if diags, err := lsp.DefaultManager.CheckFile(ctx, t.abs, t.after); err == nil {
+				if f := lsp.FormatDiagnostics(t.abs, diags); f != "" {
+					feedback = append(feedback, f)
+				}
+			}
+		}
+		if len(feedback) > 0 {
+			result.LSPFeedback = "LSP Feedback: " + strings.Join(feedback, "\n")
+		}
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrJSONMarshal, "failed to marshal result", err.Error())
+	}
+
+	return ToolResult{Output: string(resultJSON), Changes: patchChanges(targets)}, nil
+}
+
+// planPatch parses patch and works out the new content of every file it
+// touches, without writing anything. A file may appear in the patch more
+// than once; later sections apply to the result of earlier ones.
+func planPatch(patch, workingDir string) ([]*patchTarget, error) {
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	var targets []*patchTarget
+	byPath := map[string]*patchTarget{}
+	target := func(path string) (*patchTarget, error) {
+		abs, err := writablePath(path, workingDir)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := byPath[abs]; ok {
+			return t, nil
+		}
+		t := &patchTarget{path: path, abs: abs}
+		data, err := os.ReadFile(abs)
+		switch {
+		case err == nil:
+			t.before, t.existed = string(data), true
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, NewToolErrorWithDetails(ErrFileNotFound, fmt.Sprintf("cannot read %s", path), err.Error())
+		}
+		t.after, t.exists = t.before, t.existed
+		byPath[abs] = t
+		targets = append(targets, t)
+		return t, nil
+	}
+
+	for _, p := range patches {
+		var src, dst *patchTarget
+		if p.oldPath != "" {
+			if src, err = target(p.oldPath); err != nil {
+				return nil, err
+			}
+			if !src.exists {
+				return nil, NewToolError(ErrFileNotFound, fmt.Sprintf("%s does not exist", p.oldPath))
+			}
+		}
+		if p.newPath != "" {
+			if dst, err = target(p.newPath); err != nil {
+				return nil, err
+			}
+			if dst != src && dst.exists {
+				return nil, NewToolError(ErrFileExists, fmt.Sprintf("%s already exists; patch it with its own path on both --- and +++ lines", p.newPath))
+			}
+		}
+
+		content := ""
+		if src != nil {
+			content = src.after
+		}
+		patched, err := applyHunks(p.path(), content, p.hunks)
+		if err != nil {
+			return nil, err
+		}
+		if src != nil && src != dst {
+			src.after, src.exists = "", false
+		}
+		if dst != nil {
+			dst.after, dst.exists = patched, true
+			dst.hunks += len(p.hunks)
+			if src != nil && src != dst {
+				dst.movedFrom = src.path
+			}
+		} else {
+			src.hunks += len(p.hunks)
+		}
+	}
+	return targets, nil
+}
+
+// commitPatch writes the planned files and then removes the deleted ones.
+// If any step fails, the steps before it are undone.
+func commitPatch(targets []*patchTarget) error {
+	var undo []func()
+	fail := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return err
+	}
+
+	for _, t := range targets {
+		if !t.exists || (t.existed && t.after == t.before) {
+			continue
+		}
+		if !t.existed {
+			created := missingAncestor(filepath.Dir(t.abs))
+			if err := os.MkdirAll(filepath.Dir(t.abs), config.DirPermissions); err != nil {
+				return fail(NewToolErrorWithDetails(ErrFileWrite, fmt.Sprintf("failed to create directory for %s", t.path), err.Error()))
+			}
+			if created != "" {
+				undo = append(undo, func() { os.RemoveAll(created) })
+			}
+		}
+		if err := os.WriteFile(t.abs, []byte(t.after), config.FilePermissions); err != nil {
+			return fail(NewToolErrorWithDetails(ErrFileWrite, fmt.Sprintf("failed to write %s", t.path), err.Error()))
+		}
+		if t.existed {
+			undo = append(undo, func() { os.WriteFile(t.abs, []byte(t.before), config.FilePermissions) })
+		} else {
+			undo = append(undo, func() { os.Remove(t.abs) })
+		}
+	}
+
+	for _, t := range targets {
+		if !t.existed || t.exists {
+			continue
+		}
+		mode := fs.FileMode(config.FilePermissions)
+		if info, err := os.Stat(t.abs); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.Remove(t.abs); err != nil {
+			return fail(NewToolErrorWithDetails(ErrFileWrite, fmt.Sprintf("failed to delete %s", t.path), err.Error()))
+		}
+		undo = append(undo, func() { os.WriteFile(t.abs, []byte(t.before), mode) })
+	}
+	return nil
+}
+
+// missingAncestor returns the topmost directory of dir that does not exist
+// yet, or "" if dir exists.
+func missingAncestor(dir string) string {
+	missing := ""
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return missing
+		}
+		missing = dir
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return missing
+		}
+		dir = parent
+	}
+}
+
+// patchedFiles summarizes the planned changes for the model, leaving out
+// files that are only the source of a rename.
+func patchedFiles(targets []*patchTarget) []PatchedFile {
+	var files []PatchedFile
+	for _, t := range targets {
+		action, before := patchAction(targets, t)
+		if action == "" {
+			continue
+		}
+		files = append(files, PatchedFile{
+			FilePath:     t.path,
+			Action:       action,
+			MovedFrom:    t.movedFrom,
+			Hunks:        t.hunks,
+			ChangedLines: changedLines(before, t.after),
+		})
+	}
+	return files
+}
+
+// patchChanges returns the planned changes for display: the changed lines of
+// a modified or renamed file and the whole content of a created or deleted
+// one.
+func patchChanges(targets []*patchTarget) []FileChange {
+	var changes []FileChange
+	for _, t := range targets {
+		action, before := patchAction(targets, t)
+		switch action {
+		case "created":
+			changes = append(changes, FileChange{FilePath: t.path, NewText: t.after, StartLine: 1})
+		case "deleted":
+			changes = append(changes, FileChange{FilePath: t.path + " (deleted)", OldText: before, StartLine: 1})
+		case "modified":
+			changes = append(changes, changedRegion(t.path, before, t.after))
+		case "renamed":
+			if before == t.after {
+				continue // nothing to show but the name, which Format already lists
+			}
+			c := changedRegion(t.path, before, t.after)
+			c.FilePath = t.movedFrom + " → " + t.path
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// patchAction returns what the patch does to t and the content t had before,
+// which for a renamed file is that of its source. The action is "" for a
+// file that is only the source of a rename or that the patch creates and
+// deletes again.
+func patchAction(targets []*patchTarget, t *patchTarget) (action, before string) {
+	switch {
+	case t.exists && t.movedFrom != "":
+		if source := targetByPath(targets, t.movedFrom); source != nil {
+			before = source.before
+		}
+		return "renamed", before
+	case t.exists && !t.existed:
+		return "created", ""
+	case t.exists && t.after == t.before:
+		return "unchanged", t.before
+	case t.exists:
+		return "modified", t.before
+	case t.existed && !targetMovedFrom(targets, t.path):
+		return "deleted", t.before
+	}
+	return "", ""
+}
+
+func targetByPath(targets []*patchTarget, path string) *patchTarget {
+	for _, t := range targets {
+		if t.path == path {
+			return t
+		}
+	}
+	return nil
+}
+
+func targetMovedFrom(targets []*patchTarget, path string) bool {
+	for _, t := range targets {
+		if t.exists && t.movedFrom == path {
+			return true
+		}
+	}
+	return false
+}
+
+// formatApplyPatch lists the files the patch touches, so that the permission
+// prompt names all of them even when the patch turns out not to apply.
+func formatApplyPatch(args ApplyPatchArgs) string {
+	patches, err := parsePatch(args.Patch)
+	if err != nil {
+		return "Patch"
+	}
+	var names []string
+	for _, p := range patches {
+		name := p.path()
+		switch {
+		case p.oldPath == "":
+			name += " (new)"
+		case p.newPath == "":
+			name += " (deleted)"
+		case p.oldPath != p.newPath:
+			name = p.oldPath + " → " + p.newPath
+		}
+		names = append(names, name)
+	}
+	return "Patch: " + strings.Join(names, ", ")
+}
+
+// applyPatchPaths returns every path the patch reads, writes or deletes, so
+// that path rules apply to each of them.
+func applyPatchPaths(args ApplyPatchArgs) []string {
+	patches, err := parsePatch(args.Patch)
+	if err != nil {
+		return []string{}
+	}
+	paths := []string{}
+	for _, p := range patches {
+		for _, path := range []string{p.oldPath, p.newPath} {
+			if path != "" && !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// applyPatchChanges previews one change per file by applying the patch in
+// memory. Once the patch has run, the changes are in ToolResult.Changes.
+func applyPatchChanges(args ApplyPatchArgs, result, workingDir string) []FileChange {
+	if result != "" {
+		return nil
+	}
+	targets, err := planPatch(args.Patch, workingDir)
+	if err != nil {
+		return nil
+	}
+	return patchChanges(targets)
+}
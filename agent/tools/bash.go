@@ -1,75 +1,125 @@
 package tools
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"time"
-)
 
-const bashTimeout = 30 * time.Second
+	"go-tui/config"
+)
 
 type BashArgs struct {
-	Command string `json:"command"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout,omitempty"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
+	Reset           bool   `json:"reset,omitempty"`
 }
 
 func init() {
 	Register(Typed[BashArgs]{
 		ToolName:        "bash",
-		ToolDescription: "Execute a bash command and return the output. Use this to run shell commands, read files, list directories, search code, etc.",
+		ToolDescription: "Execute a bash command and return the output. Use this to run shell commands, read files, list directories, search code, etc. Commands run one after another in a persistent shell session, so cd, export and source carry over to later calls; pass reset to start a fresh session in the working directory. Long builds and test runs can pass a larger timeout; a command that times out is stopped, but the session keeps its state. Dev servers, watchers and other commands that do not finish on their own should use run_in_background, then bash_output to read their output and bash_kill to stop them. Returns JSON with exit_code, signal (if one killed the command), duration_ms, timed_out and output, which holds stdout and stderr together in the order the command wrote them.",
 		ToolSchema: json.RawMessage(`{
 			"type": "object",
 			"properties": {
 				"command": {
 					"type": "string",
 					"description": "The bash command to execute"
+				},
+				"timeout": {
+					"type": "integer",
+					"description": "Seconds to wait for the command before stopping it. Defaults to the configured timeout and is capped at the configured maximum"
+				},
+				"run_in_background": {
+					"type": "boolean",
+					"description": "Start the command without waiting for it and return a process ID for bash_output and bash_kill. It starts in the session's current directory but does not change the session"
+				},
+				"reset": {
+					"type": "boolean",
+					"description": "Restart the shell session before running the command, discarding its directory changes, variables and functions. The command may be omitted to only reset"
 				}
 			},
 			"required": ["command"]
 		}`),
-		Run: executeBash,
+		ToolInfo: Info{
+			Category:    CategoryExecute,
+			Icon:        config.BashIcon,
+			Destructive: true,
+			Permission:  PermissionAsk,
+			RuleArg:     "command",
+			RuleKind:    RuleShell,
+			Sandboxed:   true,
+		},
+		ToolFormat: formatBash,
+		Run:        executeBash,
 	})
 }
 
-func executeBash(args BashArgs, workingDir string) (ToolResult, error) {
+func executeBash(ctx context.Context, args BashArgs, workingDir string) (ToolResult, error) {
+	if args.Reset {
+		ResetShell()
+		if args.Command == "" {
+			return ToolResult{Output: "Shell session reset to " + workingDir}, nil
+		}
+	}
 	if args.Command == "" {
 		return ToolResult{}, NewToolError(ErrMissingField, "command is required")
 	}
 
-	cmd := exec.Command("bash", "-c", args.Command)
-	cmd.Dir = workingDir
+	if args.Timeout < 0 {
+		return ToolResult{}, NewToolError(ErrInvalidArguments, "timeout must not be negative")
+	}
+	if args.RunInBackground {
+		p, err := startProcess(args.Command, workingDir)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		return ToolResult{Output: fmt.Sprintf("Started background process %s. Use bash_output with id %q to read its output and bash_kill to stop it.", p.ID, p.ID)}, nil
+	}
+	timeout := bashTimeout(args.Timeout)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	result, err := runInShell(ctx, args.Command, workingDir, timeout)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	result.Output = limitOutput("bash", result.Output)
+	data, err := json.Marshal(result)
+	if err != nil {
+		return ToolResult{}, NewToolError(ErrJSONMarshal, err.Error())
+	}
+	return ToolResult{Output: string(data)}, nil
+}
 
-	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Run()
-	}()
+// BashResult is what a bash call returns to the model, as JSON.
+type BashResult struct {
+	ExitCode   int    `json:"exit_code"`        // 128+n if signal n ended the command, as bash reports it; -1 if the shell was killed
+	Signal     string `json:"signal,omitempty"` // the signal that ended it, e.g. "terminated"
+	DurationMs int64  `json:"duration_ms"`
+	TimedOut   bool   `json:"timed_out,omitempty"` // stopped after reaching its timeout
+	Output     string `json:"output"`              // stdout and stderr, in the order written
+	Note       string `json:"note,omitempty"`      // e.g. that the shell session was lost
+}
 
-	select {
-	case err := <-done:
-		output := stdout.String()
-		if stderr.Len() > 0 {
-			if output != "" {
-				output += "\n"
-			}
-			output += stderr.String()
-		}
-		if err != nil {
-			if output != "" {
-				output += "\n"
-			}
-			output += fmt.Sprintf("exit status: %v", err)
-		}
-		if output == "" {
-			output = "(no output)"
-		}
-		return ToolResult{Output: output}, nil
-	case <-time.After(bashTimeout):
-		cmd.Process.Kill()
-		return ToolResult{}, fmt.Errorf("command timed out after 30s")
+// bashTimeout returns how long a command may run: the requested number of
+// seconds, or the configured default, capped at the configured maximum.
+func bashTimeout(seconds int) time.Duration {
+	s := config.Current.Bash
+	if seconds <= 0 {
+		seconds = s.TimeoutSeconds
+	}
+	if seconds <= 0 {
+		seconds = config.Defaults().Bash.TimeoutSeconds
+	}
+	if s.MaxTimeoutSeconds > 0 && seconds > s.MaxTimeoutSeconds {
+		seconds = s.MaxTimeoutSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func formatBash(args BashArgs) string {
+	if args.RunInBackground {
+		return "Bash (background): " + args.Command
 	}
+	return "Bash: " + args.Command
 }
@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go-tui/config"
+)
+
+type BashKillArgs struct {
+	ID string `json:"id"`
+}
+
+func init() {
+	Register(Typed[BashKillArgs]{
+		ToolName:        "bash_kill",
+		ToolDescription: "Stop a background process started by bash with run_in_background.",
+		ToolSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string",
+					"description": "The process ID returned by bash, e.g. bg1"
+				}
+			},
+			"required": ["id"]
+		}`),
+		ToolInfo: Info{
+			Category:   CategoryExecute,
+			Icon:       config.BashIcon,
+			Permission: PermissionAllow,
+		},
+		ToolFormat: formatBashKill,
+		Run:        executeBashKill,
+	})
+}
+
+func executeBashKill(ctx context.Context, args BashKillArgs, workingDir string) (ToolResult, error) {
+	if args.ID == "" {
+		return ToolResult{}, NewToolError(ErrMissingField, "id is required")
+	}
+	p, err := lookupProcess(args.ID)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	if !p.Running() {
+		return ToolResult{Output: fmt.Sprintf("Process %s already %s", p.ID, p.Status())}, nil
+	}
+	p.Kill()
+	return ToolResult{Output: fmt.Sprintf("Process %s %s", p.ID, p.Status())}, nil
+}
+
+func formatBashKill(args BashKillArgs) string {
+	return "Kill: " + args.ID
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go-tui/config"
+)
+
+type BashOutputArgs struct {
+	ID string `json:"id"`
+}
+
+func init() {
+	Register(Typed[BashOutputArgs]{
+		ToolName:        "bash_output",
+		ToolDescription: "Get the status of a background process started by bash with run_in_background, and the output it produced since the last call.",
+		ToolSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"id": {
+					"type": "string",
+					"description": "The process ID returned by bash, e.g. bg1"
+				}
+			},
+			"required": ["id"]
+		}`),
+		ToolInfo: Info{
+			Category:   CategoryExecute,
+			Icon:       config.BashIcon,
+			Permission: PermissionAllow,
+		},
+		ToolFormat: formatBashOutput,
+		Run:        executeBashOutput,
+	})
+}
+
+func executeBashOutput(ctx context.Context, args BashOutputArgs, workingDir string) (ToolResult, error) {
+	if args.ID == "" {
+		return ToolResult{}, NewToolError(ErrMissingField, "id is required")
+	}
+	p, err := lookupProcess(args.ID)
+	if err != nil {
+		return ToolResult{}, err
+	}
+
+	// Read the status first so output written just before the command exited
+	// is not missed.
+	status := p.Status()
+	output := limitOutput(p.ID, p.unread())
+	if output == "" {
+		output = "(no new output)"
+	}
+	return ToolResult{Output: fmt.Sprintf("Process %s (%s): %s\n%s", p.ID, status, p.Command, output)}, nil
+}
+
+func formatBashOutput(args BashOutputArgs) string {
+	return "Output: " + args.ID
+}
@@ -2,11 +2,14 @@ package tools
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strings"
 	"time"
+
+	"go-tui/config"
 )
 
 type BeadsArgs struct {
@@ -33,11 +36,18 @@ func init() {
 			},
 			"required": ["command"]
 		}`),
-		Run: executeBeads,
+		ToolInfo: Info{
+			Category:   CategoryTask,
+			Icon:       config.ToolIcon,
+			Permission: PermissionAsk,
+			RuleArg:    "command",
+		},
+		ToolFormat: formatBeads,
+		Run:        executeBeads,
 	})
 }
 
-func executeBeads(args BeadsArgs, workingDir string) (ToolResult, error) {
+func executeBeads(ctx context.Context, args BeadsArgs, workingDir string) (ToolResult, error) {
 	if args.Command == "" {
 		return ToolResult{}, NewToolError(ErrMissingField, "command is required")
 	}
@@ -77,9 +87,20 @@ func executeBeads(args BeadsArgs, workingDir string) (ToolResult, error) {
 		if output == "" {
 			output = "(no output)"
 		}
-		return ToolResult{Output: output}, nil
+		return ToolResult{Output: limitOutput("beads", output)}, nil
 	case <-time.After(10 * time.Second):
 		cmd.Process.Kill()
 		return ToolResult{}, fmt.Errorf("bd command timed out after 10s")
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		return ToolResult{}, ctx.Err()
+	}
+}
+
+func formatBeads(args BeadsArgs) string {
+	s := "Beads: " + args.Command
+	if args.Args != "" {
+		s += " " + args.Args
 	}
+	return s
 }
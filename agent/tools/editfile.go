@@ -1,10 +1,10 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"go-tui/config"
@@ -46,11 +46,20 @@ func init() {
 			},
 			"required": ["file_path", "old_string", "new_string"]
 		}`),
-		Run: executeEditFile,
+		ToolInfo: Info{
+			Category:   CategoryEdit,
+			Icon:       config.EditIcon,
+			Permission: PermissionAsk,
+			RuleArg:    "file_path",
+			RuleKind:   RulePath,
+		},
+		ToolFormat:  formatEditFile,
+		ToolChanges: editFileChanges,
+		Run:         executeEditFile,
 	})
 }
 
-func executeEditFile(args EditFileArgs, workingDir string) (ToolResult, error) {
+func executeEditFile(ctx context.Context, args EditFileArgs, workingDir string) (ToolResult, error) {
 	if args.FilePath == "" {
 		return ToolResult{}, NewToolError(ErrMissingField, "file_path is required")
 	}
@@ -61,9 +70,9 @@ func executeEditFile(args EditFileArgs, workingDir string) (ToolResult, error) {
 		return ToolResult{}, NewToolError(ErrIdenticalContent, "old_string and new_string are identical. No changes needed. Do not retry this edit.")
 	}
 
-	path := args.FilePath
-	if !filepath.IsAbs(path) {
-		path = filepath.Join(workingDir, path)
+	path, err := writablePath(args.FilePath, workingDir)
+	if err != nil {
+		return ToolResult{}, err
 	}
 
 	data, err := os.ReadFile(path)
@@ -95,7 +104,7 @@ func executeEditFile(args EditFileArgs, workingDir string) (ToolResult, error) {
 	}
 
 	if lsp.DefaultManager != nil {
-		if diags, err := lsp.DefaultManager.CheckFile(path, newContent); err == nil {
+		if diags, err := lsp.DefaultManager.CheckFile(ctx, path, newContent); err == nil {
 			if feedback := lsp.FormatDiagnostics(path, diags); feedback != "" {
 				editResult.LSPFeedback = "LSP Feedback: " + feedback
 			}
@@ -109,3 +118,26 @@ func executeEditFile(args EditFileArgs, workingDir string) (ToolResult, error) {
 
 	return ToolResult{Output: string(resultJSON)}, nil
 }
+
+func formatEditFile(args EditFileArgs) string {
+	return "Edit: " + args.FilePath
+}
+
+// editFileChanges reports the replaced text. After the edit has run the file
+// holds new_string, so that is where the change is located.
+func editFileChanges(args EditFileArgs, result, workingDir string) []FileChange {
+	if args.FilePath == "" {
+		return nil
+	}
+	path := resolvePath(args.FilePath, workingDir)
+	line := startLine(path, args.OldString)
+	if result != "" {
+		line = startLine(path, args.NewString)
+	}
+	return []FileChange{{
+		FilePath:  args.FilePath,
+		OldText:   args.OldString,
+		NewText:   args.NewString,
+		StartLine: line,
+	}}
+}
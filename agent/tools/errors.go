@@ -33,12 +33,19 @@ func NewToolErrorWithDetails(code, message, details string) *ToolError {
 
 // Error codes
 const (
-	ErrInvalidArguments = "INVALID_ARGUMENTS"
-	ErrMissingField     = "MISSING_FIELD"
-	ErrIdenticalContent = "IDENTICAL_CONTENT"
-	ErrFileNotFound     = "FILE_NOT_FOUND"
-	ErrStringNotFound   = "STRING_NOT_FOUND"
-	ErrStringNotUnique  = "STRING_NOT_UNIQUE"
-	ErrFileWrite        = "FILE_WRITE_ERROR"
-	ErrJSONMarshal      = "JSON_MARSHAL_ERROR"
-)
\ No newline at end of file
+	ErrInvalidArguments     = "INVALID_ARGUMENTS"
+	ErrMissingField         = "MISSING_FIELD"
+	ErrIdenticalContent     = "IDENTICAL_CONTENT"
+	ErrFileNotFound         = "FILE_NOT_FOUND"
+	ErrStringNotFound       = "STRING_NOT_FOUND"
+	ErrStringNotUnique      = "STRING_NOT_UNIQUE"
+	ErrFileWrite            = "FILE_WRITE_ERROR"
+	ErrJSONMarshal          = "JSON_MARSHAL_ERROR"
+	ErrPathOutsideWorkspace = "PATH_OUTSIDE_WORKSPACE"
+	ErrSensitivePath        = "SENSITIVE_PATH"
+	ErrSandboxUnavailable   = "SANDBOX_UNAVAILABLE"
+	ErrProcessNotFound      = "PROCESS_NOT_FOUND"
+	ErrInvalidPatch         = "INVALID_PATCH"
+	ErrHunkMismatch         = "HUNK_MISMATCH"
+	ErrFileExists           = "FILE_EXISTS"
+)
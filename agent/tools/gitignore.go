@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern line of a .gitignore file.
+type ignoreRule struct {
+	base    string // directory of the .gitignore, slash-separated and relative to the walk root; "" for the root
+	re      *regexp.Regexp
+	negate  bool // "!pattern" re-includes what earlier rules excluded
+	dirOnly bool // "pattern/" only matches directories
+	byName  bool // a pattern without a slash matches the name at any depth
+}
+
+// gitIgnore holds the rules in effect for a directory: those of its own
+// .gitignore and of every directory above it, in the order git applies them.
+type gitIgnore struct {
+	rules []ignoreRule
+}
+
+// withFile returns the rules extended by the .gitignore in dir, whose path
+// relative to the walk root is rel. The receiver is not modified, so sibling
+// directories can share it.
+func (g *gitIgnore) withFile(dir, rel string) *gitIgnore {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return g
+	}
+	defer f.Close()
+
+	var added []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseIgnoreLine(scanner.Text(), rel); ok {
+			added = append(added, r)
+		}
+	}
+	if len(added) == 0 {
+		return g
+	}
+	var rules []ignoreRule
+	if g != nil {
+		rules = append(rules, g.rules...)
+	}
+	return &gitIgnore{rules: append(rules, added...)}
+}
+
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	r := ignoreRule{base: base}
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		r.negate, line = true, rest
+	}
+	line = strings.TrimPrefix(line, `\`) // escapes a leading ! or #
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		r.dirOnly, line = true, rest
+	}
+	r.byName = !strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	re, err := compileGlob(line)
+	if line == "" || err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// ignored reports whether the entry at rel, a slash-separated path relative
+// to the walk root, is excluded. The last matching rule decides.
+func (g *gitIgnore) ignored(rel string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if r.base != "" {
+			var ok bool
+			if sub, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+				continue
+			}
+		}
+		if r.byName {
+			sub = path.Base(sub)
+		}
+		if r.re.MatchString(sub) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// compileGlob compiles a gitignore-style glob to an anchored regexp: * and ?
+// do not cross a slash, ** crosses any number of directories, and [...]
+// matches a character class.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if rest, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + rest
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
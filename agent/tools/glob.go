@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go-tui/config"
+)
+
+const globDefaultLimit = 100 // files returned when the call sets no limit
+
+type GlobArgs struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+func init() {
+	Register(Typed[GlobArgs]{
+		ToolName:        "glob",
+		ToolDescription: "Find files by name with a glob pattern such as \"**/*.go\" or \"src/**/handler_*.ts\". Respects .gitignore. Returns matching paths, most recently modified first. Use this instead of listing directories recursively to locate files.",
+		ToolSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"pattern": {
+					"type": "string",
+					"description": "Glob matched against paths relative to path: * and ? stay within a directory, ** spans directories. A pattern without a slash matches file names at any depth"
+				},
+				"path": {
+					"type": "string",
+					"description": "Directory to search in (default: current directory)"
+				},
+				"limit": {
+					"type": "integer",
+					"description": "Maximum number of files to return (default 100)"
+				}
+			},
+			"required": ["pattern"]
+		}`),
+		ToolInfo: Info{
+			Category:   CategoryFind,
+			Icon:       config.GlobIcon,
+			ReadOnly:   true,
+			Permission: PermissionAllow,
+			RuleArg:    "path",
+			RuleKind:   RulePath,
+		},
+		ToolFormat: formatGlob,
+		Run:        executeGlob,
+	})
+}
+
+func executeGlob(ctx context.Context, args GlobArgs, workingDir string) (ToolResult, error) {
+	if args.Pattern == "" {
+		return ToolResult{}, NewToolError(ErrMissingField, "pattern is required")
+	}
+	if args.Limit < 0 {
+		return ToolResult{}, NewToolError(ErrInvalidArguments, "limit must not be negative")
+	}
+	if args.Limit == 0 {
+		args.Limit = globDefaultLimit
+	}
+	root, err := allowedPath(args.Path, workingDir)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrFileNotFound, "path not found", err.Error())
+	} else if !info.IsDir() {
+		return ToolResult{}, NewToolError(ErrInvalidArguments, fmt.Sprintf("%s is not a directory", args.Path))
+	}
+	globs, err := compileGlobs([]string{strings.TrimPrefix(args.Pattern, "./")})
+	if err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrInvalidArguments, "invalid pattern", err.Error())
+	}
+
+	type match struct {
+		path    string
+		modTime time.Time
+	}
+	var matches []match
+	err = walkTree(ctx, root, workingDir, func(path string, d fs.DirEntry, depth int) error {
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if !matchGlobs(globs, filepath.ToSlash(rel)) {
+			return nil
+		}
+		m := match{path: path}
+		if info, err := d.Info(); err == nil {
+			m.modTime = info.ModTime()
+		}
+		matches = append(matches, m)
+		return nil
+	})
+	if err != nil {
+		return ToolResult{}, err
+	}
+	if len(matches) == 0 {
+		return ToolResult{Output: "No files found."}, nil
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].modTime.After(matches[j].modTime)
+	})
+	var sb strings.Builder
+	for i, m := range matches {
+		if i == args.Limit {
+			fmt.Fprintf(&sb, "... (%d more files; narrow the pattern or raise limit)\n", len(matches)-i)
+			break
+		}
+		name := m.path
+		if rel, err := filepath.Rel(workingDir, m.path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		sb.WriteString(name + "\n")
+	}
+	return ToolResult{Output: sb.String()}, nil
+}
+
+func formatGlob(args GlobArgs) string {
+	s := "Glob: " + args.Pattern
+	if args.Path != "" {
+		s += " in " + args.Path
+	}
+	return s
+}
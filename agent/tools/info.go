@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"os"
+	"strings"
+
+	"go-tui/config"
+)
+
+// Category groups tools by what they do.
+type Category string
+
+const (
+	CategoryRead    Category = "read"    // reads file contents
+	CategoryList    Category = "list"    // lists directory entries
+	CategorySearch  Category = "search"  // searches file contents
+	CategoryFind    Category = "find"    // finds files by name
+	CategoryEdit    Category = "edit"    // changes files
+	CategoryExecute Category = "execute" // runs commands
+	CategoryTask    Category = "task"    // manages the task tracker
+)
+
+// Permission is what happens to a call the user has not decided on.
+type Permission string
+
+const (
+	PermissionAsk   Permission = "ask"   // prompt the user
+	PermissionAllow Permission = "allow" // run without prompting
+	PermissionDeny  Permission = "deny"  // refuse without prompting
+)
+
+// Info describes a tool to the UI and the permission system.
+type Info struct {
+	Category    Category
+	Icon        string     // shown before the call in the transcript
+	ReadOnly    bool       // only inspects state; calls may run concurrently
+	Destructive bool       // may change or delete data that cannot be recovered
+	Permission  Permission // default for calls the user has not decided on
+	RuleArg     string     // argument permission rules match against, e.g. "command"
+	RuleKind    RuleKind   // how rules match RuleArg
+	Sandboxed   bool       // runs inside the sandbox when one is enabled
+}
+
+// RuleKind says how permission rules match a tool's rule argument.
+type RuleKind int
+
+const (
+	RuleText  RuleKind = iota // plain text, where * matches anything
+	RulePath                  // a file path, matched as a glob relative to the working directory
+	RuleShell                 // a shell command line; rules must match every command in it
+)
+
+// FileChange is one file modified by a tool call.
+type FileChange struct {
+	FilePath  string
+	OldText   string
+	NewText   string
+	StartLine int // 1-based line where OldText starts in the file
+}
+
+// Lookup returns the registered tool with the given name.
+func Lookup(name string) (ToolImpl, bool) {
+	t, ok := registry[name]
+	return t, ok
+}
+
+// InfoFor returns the metadata of the named tool. Unknown tools get the
+// generic icon and require permission.
+func InfoFor(name string) Info {
+	if t, ok := registry[name]; ok {
+		return t.Info()
+	}
+	return Info{Icon: config.ToolIcon, Permission: PermissionAsk}
+}
+
+// startLine returns the 1-based line of the first of needles found in the
+// file, or 1.
+func startLine(path string, needles ...string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 1
+	}
+	for _, needle := range needles {
+		if needle == "" {
+			continue
+		}
+		if idx := strings.Index(string(data), needle); idx >= 0 {
+			return strings.Count(string(data[:idx]), "\n") + 1
+		}
+	}
+	return 1
+}
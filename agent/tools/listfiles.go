@@ -1,22 +1,36 @@
 package tools
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"go-tui/config"
+)
+
+const (
+	listDefaultLimit = 200      // entries shown when the call sets no limit
+	listMaxLineCount = 10 << 20 // larger files get no line count
 )
 
 type ListFilesArgs struct {
-	Path      string `json:"path,omitempty"`
-	Recursive bool   `json:"recursive,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Recursive  bool   `json:"recursive,omitempty"`
+	MaxDepth   int    `json:"max_depth,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+	Sizes      bool   `json:"sizes,omitempty"`
+	LineCounts bool   `json:"line_counts,omitempty"`
 }
 
 func init() {
 	Register(Typed[ListFilesArgs]{
 		ToolName:        "list_files",
-		ToolDescription: "List files and directories at the given path. Directories have a trailing '/'. Skips .git.",
+		ToolDescription: "List files and directories at the given path as an indented tree. Directories have a trailing '/'; those below max_depth show how many entries they hold. Respects .gitignore and skips .git. To find files by name, prefer glob.",
 		ToolSchema: json.RawMessage(`{
 			"type": "object",
 			"properties": {
@@ -26,20 +40,43 @@ func init() {
 				},
 				"recursive": {
 					"type": "boolean",
-					"description": "List files recursively (default: false)"
+					"description": "List subdirectories too, as deep as max_depth allows (default: false)"
+				},
+				"max_depth": {
+					"type": "integer",
+					"description": "How many directory levels to show; 1 lists only the entries of path. Defaults to 1, or unlimited if recursive"
+				},
+				"limit": {
+					"type": "integer",
+					"description": "Maximum number of entries to show (default 200)"
+				},
+				"sizes": {
+					"type": "boolean",
+					"description": "Show file sizes"
+				},
+				"line_counts": {
+					"type": "boolean",
+					"description": "Show the number of lines of text files"
 				}
 			}
 		}`),
-		Run: executeListFiles,
+		ToolInfo: Info{
+			Category:   CategoryList,
+			Icon:       config.ListIcon,
+			ReadOnly:   true,
+			Permission: PermissionAllow,
+			RuleArg:    "path",
+			RuleKind:   RulePath,
+		},
+		ToolFormat: formatListFiles,
+		Run:        executeListFiles,
 	})
 }
 
-func executeListFiles(args ListFilesArgs, workingDir string) (ToolResult, error) {
-	path := args.Path
-	if path == "" {
-		path = workingDir
-	} else if !filepath.IsAbs(path) {
-		path = filepath.Join(workingDir, path)
+func executeListFiles(ctx context.Context, args ListFilesArgs, workingDir string) (ToolResult, error) {
+	path, err := allowedPath(args.Path, workingDir)
+	if err != nil {
+		return ToolResult{}, err
 	}
 
 	info, err := os.Stat(path)
@@ -50,40 +87,131 @@ func executeListFiles(args ListFilesArgs, workingDir string) (ToolResult, error)
 		return ToolResult{}, NewToolError(ErrInvalidArguments, fmt.Sprintf("%s is not a directory", args.Path))
 	}
 
-	var sb strings.Builder
+	if args.MaxDepth < 0 || args.Limit < 0 {
+		return ToolResult{}, NewToolError(ErrInvalidArguments, "max_depth and limit must not be negative")
+	}
+	l := fileLister{ctx: ctx, args: args, maxDepth: args.MaxDepth, budget: args.Limit}
+	if l.maxDepth == 0 {
+		l.maxDepth = 1
+		if args.Recursive {
+			l.maxDepth = math.MaxInt
+		}
+	}
+	if l.budget == 0 {
+		l.budget = listDefaultLimit
+	}
+	if err := l.list(rootDir(path, workingDir), 1); err != nil {
+		return ToolResult{}, err
+	}
+	if l.sb.Len() == 0 {
+		return ToolResult{Output: "(empty directory)"}, nil
+	}
+	return ToolResult{Output: l.sb.String()}, nil
+}
+
+// fileLister renders a directory as a tree indented by two spaces per level,
+// which costs far fewer tokens than a full path per line.
+type fileLister struct {
+	ctx      context.Context
+	args     ListFilesArgs
+	maxDepth int
+	budget   int // entries left to show
+	sb       strings.Builder
+}
 
-	if args.Recursive {
-		filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			if info.Name() == ".git" && info.IsDir() {
-				return filepath.SkipDir
-			}
-			rel, _ := filepath.Rel(workingDir, p)
-			name := rel
-			if info.IsDir() {
-				name += "/"
-			}
-			sb.WriteString(name + "\n")
+func (l *fileLister) list(dir treeDir, depth int) error {
+	entries, ignore := dir.entries()
+	indent := strings.Repeat("  ", depth-1)
+	for i, e := range entries {
+		if err := l.ctx.Err(); err != nil {
+			return err
+		}
+		if l.budget == 0 {
+			fmt.Fprintf(&l.sb, "%s... %s more\n", indent, plural(len(entries)-i, "entry", "entries"))
 			return nil
-		})
-	} else {
-		entries, err := os.ReadDir(path)
-		if err != nil {
-			return ToolResult{}, NewToolErrorWithDetails(ErrFileNotFound, "failed to read directory", err.Error())
 		}
-		for _, entry := range entries {
-			if entry.Name() == ".git" {
-				continue
-			}
-			name := entry.Name()
-			if entry.IsDir() {
-				name += "/"
+		l.budget--
+
+		name := e.Name()
+		if !e.IsDir() {
+			l.sb.WriteString(indent + name + l.details(filepath.Join(dir.path, name)) + "\n")
+			continue
+		}
+		child := dir.child(name, ignore)
+		if depth >= l.maxDepth {
+			n, _ := child.entries()
+			fmt.Fprintf(&l.sb, "%s%s/ (%s)\n", indent, name, plural(len(n), "entry", "entries"))
+			continue
+		}
+		l.sb.WriteString(indent + name + "/\n")
+		if err := l.list(child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// details returns the requested size and line count of a file, e.g.
+// " (1.2 KB, 40 lines)".
+func (l *fileLister) details(path string) string {
+	if !l.args.Sizes && !l.args.LineCounts {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	var parts []string
+	if l.args.Sizes {
+		parts = append(parts, formatSize(info.Size()))
+	}
+	if l.args.LineCounts && info.Mode().IsRegular() && info.Size() <= listMaxLineCount {
+		if data, err := os.ReadFile(path); err == nil {
+			if bytes.IndexByte(data[:min(len(data), searchBinarySniff)], 0) >= 0 {
+				parts = append(parts, "binary")
+			} else {
+				lines := bytes.Count(data, []byte("\n"))
+				if len(data) > 0 && data[len(data)-1] != '\n' {
+					lines++
+				}
+				parts = append(parts, plural(lines, "line", "lines"))
 			}
-			sb.WriteString(name + "\n")
 		}
 	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// formatSize formats a byte count for people, e.g. "1.2 KB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
 
-	return ToolResult{Output: sb.String()}, nil
+// plural returns n followed by the singular or plural noun.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+func formatListFiles(args ListFilesArgs) string {
+	path := args.Path
+	if path == "" {
+		path = "."
+	}
+	return "List: " + path
 }
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"go-tui/config"
+	"go-tui/lsp"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+type MultiEditArgs struct {
+	FilePath string     `json:"file_path"`
+	Edits    []EditSpec `json:"edits"`
+}
+
+// EditSpec is one replacement of a multi_edit call.
+type EditSpec struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+// MultiEditResult tells the model where the edits changed the file. The
+// changed text itself is only shown to the user.
+type MultiEditResult struct {
+	FilePath     string      `json:"file_path"`
+	Replacements int         `json:"replacements"`
+	ChangedLines []LineRange `json:"changed_lines"`
+	LSPFeedback  string      `json:"lsp_feedback,omitempty"`
+}
+
+// LineRange is a run of changed lines, as in a hunk header: it starts at
+// line Start of the new file and replaced OldLines lines with NewLines. A
+// pure deletion has NewLines 0 and starts at the line after it.
+type LineRange struct {
+	Start    int `json:"start"`
+	OldLines int `json:"old_lines"`
+	NewLines int `json:"new_lines"`
+}
+
+func init() {
+	Register(Typed[MultiEditArgs]{
+		ToolName:        "multi_edit",
+		ToolDescription: "Make several edits to one file in a single call. Edits are applied in order, each to the result of the previous one; each old_string must be unique unless replace_all is set. If any edit fails, none are applied. Prefer this over repeated edit_file calls on the same file. NOTE: After editing, the system runs LSP diagnostics and provides feedback in the result.",
+		ToolSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"file_path": {
+					"type": "string",
+					"description": "Path to the file to edit (relative to working directory or absolute)"
+				},
+				"edits": {
+					"type": "array",
+					"description": "Replacements to apply in order",
+					"items": {
+						"type": "object",
+						"properties": {
+							"old_string": {
+								"type": "string",
+								"description": "The exact text to find and replace"
+							},
+							"new_string": {
+								"type": "string",
+								"description": "The replacement text"
+							},
+							"replace_all": {
+								"type": "boolean",
+								"description": "Replace every occurrence instead of requiring a unique match (default: false)"
+							}
+						},
+						"required": ["old_string", "new_string"]
+					}
+				}
+			},
+			"required": ["file_path", "edits"]
+		}`),
+		ToolInfo: Info{
+			Category:   CategoryEdit,
+			Icon:       config.EditIcon,
+			Permission: PermissionAsk,
+			RuleArg:    "file_path",
+			RuleKind:   RulePath,
+		},
+		ToolFormat:  formatMultiEdit,
+		ToolChanges: multiEditChanges,
+		Run:         executeMultiEdit,
+	})
+}
+
+func executeMultiEdit(ctx context.Context, args MultiEditArgs, workingDir string) (ToolResult, error) {
+	if args.FilePath == "" {
+		return ToolResult{}, NewToolError(ErrMissingField, "file_path is required")
+	}
+	if len(args.Edits) == 0 {
+		return ToolResult{}, NewToolError(ErrMissingField, "edits is required")
+	}
+
+	path, err := writablePath(args.FilePath, workingDir)
+	if err != nil {
+		return ToolResult{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrFileNotFound, "file not found", err.Error())
+	}
+
+	content := string(data)
+	newContent, replacements, err := applyEdits(content, args.Edits)
+	if err != nil {
+		return ToolResult{}, err
+	}
+
+	if err := os.WriteFile(path, []byte(newContent), config.FilePermissions); err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrFileWrite, "failed to write file", err.Error())
+	}
+
+	editResult := MultiEditResult{
+		FilePath:     args.FilePath,
+		Replacements: replacements,
+		ChangedLines: changedLines(content, newContent),
+	}
+
+	if lsp.DefaultManager != nil {
+		if diags, err := lsp.DefaultManager.CheckFile(ctx, path, newContent); err == nil {
+			if feedback := lsp.FormatDiagnostics(path, diags); feedback != "" {
+				editResult.LSPFeedback = "LSP Feedback: " + feedback
+			}
+		}
+	}
+
+	resultJSON, err := json.Marshal(editResult)
+	if err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrJSONMarshal, "failed to marshal result", err.Error())
+	}
+
+	return ToolResult{
+		Output:  string(resultJSON),
+		Changes: []FileChange{changedRegion(args.FilePath, content, newContent)},
+	}, nil
+}
+
+// applyEdits applies edits to content in order and returns the result and
+// the number of replacements made. Nothing is returned unless every edit
+// applies.
+func applyEdits(content string, edits []EditSpec) (string, int, error) {
+	replacements := 0
+	for i, e := range edits {
+		n := i + 1
+		if e.OldString == "" {
+			return "", 0, NewToolError(ErrMissingField, fmt.Sprintf("edit %d: old_string is required", n))
+		}
+		if e.OldString == e.NewString {
+			return "", 0, NewToolError(ErrIdenticalContent, fmt.Sprintf("edit %d: old_string and new_string are identical. Remove this edit and retry.", n))
+		}
+		count := strings.Count(content, e.OldString)
+		if count == 0 {
+			return "", 0, NewToolError(ErrStringNotFound, fmt.Sprintf("edit %d: old_string not found in file. Earlier edits in the list may have changed it. No edits were applied; use read_file to check the current content before retrying.", n))
+		}
+		if count > 1 && !e.ReplaceAll {
+			return "", 0, NewToolErrorWithDetails(ErrStringNotUnique, fmt.Sprintf("edit %d: old_string found multiple times", n),
+				fmt.Sprintf("found %d times, must be unique. Include more surrounding context or set replace_all. No edits were applied.", count))
+		}
+		if e.ReplaceAll {
+			content = strings.ReplaceAll(content, e.OldString, e.NewString)
+			replacements += count
+		} else {
+			content = strings.Replace(content, e.OldString, e.NewString, 1)
+			replacements++
+		}
+	}
+	return content, replacements, nil
+}
+
+// changedRegion returns the whole lines that differ between before and after,
+// from the first changed line to the last. A pure insertion includes the line
+// above it, so the change never looks like a new file.
+func changedRegion(filePath, before, after string) FileChange {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+
+	start := strings.LastIndexByte(before[:prefix], '\n') + 1
+	oldEnd, newEnd := len(before)-suffix, len(after)-suffix
+	// The text after the ends is the same in both, so is the distance to the
+	// next line break.
+	if i := strings.IndexByte(before[oldEnd:], '\n'); i >= 0 {
+		oldEnd, newEnd = oldEnd+i, newEnd+i
+	} else {
+		oldEnd, newEnd = len(before), len(after)
+	}
+	if oldEnd == start && start > 0 {
+		start = strings.LastIndexByte(before[:start-1], '\n') + 1
+	}
+	return FileChange{
+		FilePath:  filePath,
+		OldText:   before[start:oldEnd],
+		NewText:   after[start:newEnd],
+		StartLine: strings.Count(before[:start], "\n") + 1,
+	}
+}
+
+// changedLines returns the runs of lines that differ between before and
+// after.
+func changedLines(before, after string) []LineRange {
+	dmp := diffmatchpatch.New()
+	a, b, lines := dmp.DiffLinesToChars(before, after)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lines)
+
+	var ranges []LineRange
+	line, open := 1, false
+	for _, d := range diffs {
+		n := strings.Count(d.Text, "\n")
+		if !strings.HasSuffix(d.Text, "\n") && d.Text != "" {
+			n++
+		}
+		if d.Type == diffmatchpatch.DiffEqual {
+			line += n
+			open = false
+			continue
+		}
+		if !open {
+			ranges = append(ranges, LineRange{Start: line})
+			open = true
+		}
+		r := &ranges[len(ranges)-1]
+		if d.Type == diffmatchpatch.DiffDelete {
+			r.OldLines += n
+		} else {
+			r.NewLines += n
+			line += n
+		}
+	}
+	return ranges
+}
+
+func formatMultiEdit(args MultiEditArgs) string {
+	return fmt.Sprintf("Edit: %s (%s)", args.FilePath, plural(len(args.Edits), "edit", "edits"))
+}
+
+// multiEditChanges previews the changed lines as one change by applying the
+// edits to the file in memory. Once the edits have run, the change is in
+// ToolResult.Changes.
+func multiEditChanges(args MultiEditArgs, result, workingDir string) []FileChange {
+	if args.FilePath == "" || result != "" {
+		return nil
+	}
+	data, err := os.ReadFile(resolvePath(args.FilePath, workingDir))
+	if err != nil {
+		return nil
+	}
+	newContent, _, err := applyEdits(string(data), args.Edits)
+	if err != nil {
+		return nil
+	}
+	change := changedRegion(args.FilePath, string(data), newContent)
+	return []FileChange{change}
+}
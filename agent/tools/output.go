@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go-tui/config"
+)
+
+var (
+	spillMu    sync.Mutex
+	spillDir   string // created on first use, removed by RemoveSpillFiles
+	spillCount int
+)
+
+// limitOutput caps command output at the configured line and byte limits
+// before the model sees it, keeping the beginning and the end. When anything
+// is cut, the full output is saved to a spill file that read_file can page
+// through, and the marker in place of the cut says where it is.
+func limitOutput(tool, output string) string {
+	limits := config.Current.ToolOutput
+	maxLines, maxBytes := limits.MaxLines, limits.MaxBytes
+	if maxLines <= 0 {
+		maxLines = math.MaxInt
+	}
+	if maxBytes <= 0 {
+		maxBytes = math.MaxInt
+	}
+	total := strings.Count(output, "\n")
+	if !strings.HasSuffix(output, "\n") {
+		total++
+	}
+	if total <= maxLines && len(output) <= maxBytes {
+		return output
+	}
+
+	head := headOf(output, maxLines/2, maxBytes/2)
+	tail := tailOf(output[len(head):], maxLines-maxLines/2, maxBytes-maxBytes/2)
+	omitted := output[len(head) : len(output)-len(tail)]
+
+	marker := fmt.Sprintf("[... %d bytes omitted", len(omitted))
+	if n := strings.Count(omitted, "\n"); n > 0 {
+		marker = fmt.Sprintf("[... %d lines (%d bytes) omitted", n, len(omitted))
+	}
+	if path, err := spill(tool, output); err == nil {
+		marker += fmt.Sprintf("; full output (%d lines) saved to %s, read it with read_file offset/limit", total, path)
+	}
+	marker += " ...]"
+	if head != "" && !strings.HasSuffix(head, "\n") {
+		head += "\n"
+	}
+	if tail != "" {
+		marker += "\n"
+	}
+	return head + marker + tail
+}
+
+// headOf returns the longest prefix of s with at most lines lines and bytes
+// bytes, cut at a line break unless the first line alone is too long.
+func headOf(s string, lines, bytes int) string {
+	end := 0
+	for n := 0; n < lines && end < len(s); n++ {
+		next := len(s)
+		if i := strings.IndexByte(s[end:], '\n'); i >= 0 {
+			next = end + i + 1
+		}
+		if next > bytes {
+			if n == 0 {
+				return truncateUTF8(s, bytes)
+			}
+			break
+		}
+		end = next
+	}
+	return s[:end]
+}
+
+// tailOf returns the longest suffix of s with at most lines lines and bytes
+// bytes, starting at a line unless the last line alone is too long.
+func tailOf(s string, lines, bytes int) string {
+	start := len(s)
+	for n := 0; n < lines && start > 0; n++ {
+		// The line before start begins after the line break preceding its own.
+		prev := strings.LastIndexByte(s[:start-1], '\n') + 1
+		if len(s)-prev > bytes {
+			if n == 0 {
+				cut := len(s) - bytes
+				for cut < len(s) && !utf8.RuneStart(s[cut]) {
+					cut++
+				}
+				return s[cut:]
+			}
+			break
+		}
+		start = prev
+	}
+	return s[start:]
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// spill saves output to a new file in the spill directory.
+func spill(tool, output string) (string, error) {
+	spillMu.Lock()
+	defer spillMu.Unlock()
+	if spillDir == "" {
+		dir, err := os.MkdirTemp("", "go-tui-output-")
+		if err != nil {
+			return "", err
+		}
+		spillDir = dir
+	}
+	spillCount++
+	path := filepath.Join(spillDir, fmt.Sprintf("%s-%d.log", tool, spillCount))
+	if err := os.WriteFile(path, []byte(output), config.FilePermissions); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// spillRoot returns the spill directory, or "" if nothing was spilled yet.
+func spillRoot() string {
+	spillMu.Lock()
+	defer spillMu.Unlock()
+	return spillDir
+}
+
+// RemoveSpillFiles deletes the full outputs saved by limitOutput.
+func RemoveSpillFiles() {
+	spillMu.Lock()
+	defer spillMu.Unlock()
+	if spillDir != "" {
+		os.RemoveAll(spillDir)
+		spillDir = ""
+	}
+}
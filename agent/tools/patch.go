@@ -0,0 +1,355 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// filePatch is the part of a unified diff that changes one file.
+type filePatch struct {
+	oldPath string // "" when the patch creates the file
+	newPath string // "" when the patch deletes the file
+	hunks   []hunk
+}
+
+// hunk is one @@ section of a file patch.
+type hunk struct {
+	oldStart int // line the hunk starts at in the original file, as the header claims; -1 if it has no numbers
+	lines    []hunkLine
+	oldNoEOL bool // the old side ends the file without a final newline
+	newNoEOL bool // the new side ends the file without a final newline
+}
+
+type hunkLine struct {
+	op   byte // ' ' for context, '-' for removed, '+' for added
+	text string
+}
+
+// maxFuzz is how many context lines at each end of a hunk may be ignored
+// when it does not match with all of them.
+const maxFuzz = 2
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// parsePatch splits a unified diff into its file patches. Text outside the
+// file headers and hunks, such as git's "diff --git" and "index" lines, is
+// ignored. Hunk headers may leave out the line numbers.
+func parsePatch(text string) ([]filePatch, error) {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	var patches []filePatch
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case isFileHeader(lines, i):
+			p := filePatch{oldPath: headerPath(line[4:]), newPath: headerPath(lines[i+1][4:])}
+			if p.oldPath == "" && p.newPath == "" {
+				return nil, NewToolError(ErrInvalidPatch, fmt.Sprintf("line %d: both sides of the file header are /dev/null", i+1))
+			}
+			patches = append(patches, stripGitPrefixes(p))
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if len(patches) == 0 {
+				return nil, NewToolError(ErrInvalidPatch, fmt.Sprintf("line %d: hunk before the first ---/+++ file header", i+1))
+			}
+			h, end, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			p := &patches[len(patches)-1]
+			p.hunks = append(p.hunks, h)
+			i = end
+		}
+	}
+	if len(patches) == 0 {
+		return nil, NewToolError(ErrInvalidPatch, "no file headers found; the patch must be a unified diff with ---/+++ lines and @@ hunks")
+	}
+	for _, p := range patches {
+		if len(p.hunks) == 0 {
+			return nil, NewToolError(ErrInvalidPatch, fmt.Sprintf("no hunks for %s", p.path()))
+		}
+	}
+	return patches, nil
+}
+
+// parseHunk parses the hunk whose header is lines[start] and returns it with
+// the index of its last line.
+func parseHunk(lines []string, start int) (hunk, int, error) {
+	h := hunk{oldStart: -1}
+	if m := hunkHeaderRe.FindStringSubmatch(lines[start]); m != nil {
+		h.oldStart, _ = strconv.Atoi(m[1])
+	}
+	// Editors and models often drop the space of empty context lines. They
+	// are held back so that blank lines after the hunk are not taken as part
+	// of it.
+	blanks := 0
+	end := start
+	for i := start + 1; i < len(lines) && !isHunkEnd(lines, i); i++ {
+		line := lines[i]
+		if line == "" {
+			blanks++
+			continue
+		}
+		switch line[0] {
+		case ' ', '-', '+':
+			for ; blanks > 0; blanks-- {
+				h.lines = append(h.lines, hunkLine{op: ' '})
+			}
+			h.lines = append(h.lines, hunkLine{op: line[0], text: line[1:]})
+		case '\\':
+			// "\ No newline at end of file" refers to the line above.
+			if len(h.lines) > 0 && blanks == 0 {
+				switch h.lines[len(h.lines)-1].op {
+				case '-':
+					h.oldNoEOL = true
+				case '+':
+					h.newNoEOL = true
+				default:
+					h.oldNoEOL, h.newNoEOL = true, true
+				}
+			}
+		default:
+			return hunk{}, 0, NewToolError(ErrInvalidPatch,
+				fmt.Sprintf("line %d: %q is not a hunk line; hunk lines start with ' ', '-' or '+'", i+1, truncateLine(line)))
+		}
+		end = i
+	}
+	return h, end, nil
+}
+
+// isFileHeader reports whether lines[i] starts a ---/+++ file header.
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+func isHunkEnd(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "@@") || strings.HasPrefix(lines[i], "diff ") || isFileHeader(lines, i)
+}
+
+// headerPath returns the path of a ---/+++ line without its timestamp, or ""
+// for /dev/null.
+func headerPath(s string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		s = unquoted
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// stripGitPrefixes removes the a/ and b/ prefixes git puts on both paths.
+func stripGitPrefixes(p filePatch) filePatch {
+	oldOK := p.oldPath == "" || strings.HasPrefix(p.oldPath, "a/")
+	newOK := p.newPath == "" || strings.HasPrefix(p.newPath, "b/")
+	if oldOK && newOK {
+		p.oldPath = strings.TrimPrefix(p.oldPath, "a/")
+		p.newPath = strings.TrimPrefix(p.newPath, "b/")
+	}
+	return p
+}
+
+// path returns the path the patch is best known by.
+func (p filePatch) path() string {
+	if p.newPath != "" {
+		return p.newPath
+	}
+	return p.oldPath
+}
+
+// sides returns the lines the hunk expects and the lines it puts in their place.
+func (h hunk) sides() (old, new []string) {
+	for _, l := range h.lines {
+		if l.op != '+' {
+			old = append(old, l.text)
+		}
+		if l.op != '-' {
+			new = append(new, l.text)
+		}
+	}
+	return old, new
+}
+
+// replacement returns what the file lines matched by h, less lead leading
+// and trail trailing context lines, become. Context lines keep the file's
+// text, which may differ from the patch in whitespace; removed lines are
+// dropped and added lines inserted.
+func (h hunk) replacement(matched []string, lead, trail int) []string {
+	var out []string
+	i := 0
+	for _, l := range h.lines[lead : len(h.lines)-trail] {
+		switch l.op {
+		case ' ':
+			out = append(out, matched[i])
+			i++
+		case '-':
+			i++
+		case '+':
+			out = append(out, l.text)
+		}
+	}
+	return out
+}
+
+// context returns the number of context lines at the start and end of h.
+func (h hunk) context() (lead, trail int) {
+	for lead < len(h.lines) && h.lines[lead].op == ' ' {
+		lead++
+	}
+	if lead == len(h.lines) {
+		return lead, 0
+	}
+	for trail < len(h.lines) && h.lines[len(h.lines)-1-trail].op == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+// applyHunks applies the hunks of the file at path to content in order. Each
+// hunk is looked for near the line its header names, shifted by where the
+// hunks before it were found; without line numbers, after the previous hunk.
+// A hunk that does not match exactly may match with trailing or all
+// surrounding whitespace ignored, and then with up to maxFuzz context lines
+// at each end ignored.
+func applyHunks(path, content string, hunks []hunk) (string, error) {
+	lines, eol := splitLines(content)
+	shift, from := 0, 0
+	for n, h := range hunks {
+		old, new := h.sides()
+		var pos, lead, trail int
+		if len(old) == 0 {
+			// A pure insertion: the header names the line it follows.
+			switch {
+			case h.oldStart >= 0:
+				pos = min(max(h.oldStart+shift, 0), len(lines))
+			case len(lines) == 0:
+				pos = 0
+			default:
+				return "", NewToolError(ErrHunkMismatch,
+					fmt.Sprintf("%s: hunk %d has neither context lines nor line numbers to place it by", path, n+1))
+			}
+		} else {
+			var ok bool
+			hint := from
+			if h.oldStart > 0 {
+				hint = h.oldStart - 1 + shift
+			}
+			if pos, lead, trail, ok = findHunk(lines, h, old, hint, h.oldStart <= 0); !ok {
+				return "", hunkMismatch(path, n, old)
+			}
+		}
+		replacement := h.replacement(lines[pos:], lead, trail)
+		lines = slices.Replace(lines, pos, pos+len(old)-lead-trail, replacement...)
+		if len(old) > 0 && h.oldStart > 0 {
+			shift = pos - lead - (h.oldStart - 1)
+		}
+		shift += len(new) - len(old)
+		from = pos + len(replacement)
+		if from == len(lines) {
+			// The markers only mean something for the end of the file.
+			if h.newNoEOL {
+				eol = false
+			} else if h.oldNoEOL {
+				eol = true
+			}
+		}
+	}
+	return joinLines(lines, eol), nil
+}
+
+// findHunk returns where the old lines of h, less lead leading and trail
+// trailing context lines, are in lines. Of several matches it picks the one
+// closest to hint or, if after is set, the first one from hint on.
+func findHunk(lines []string, h hunk, old []string, hint int, after bool) (pos, lead, trail int, ok bool) {
+	maxLead, maxTrail := h.context()
+	equal := []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+		func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) },
+	}
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		lead, trail = min(fuzz, maxLead), min(fuzz, maxTrail)
+		if fuzz > 0 && lead+trail < fuzz {
+			break // no more context to give up
+		}
+		want := old[lead : len(old)-trail]
+		if len(want) == 0 {
+			break
+		}
+		target := hint + lead
+		for _, eq := range equal {
+			best, bestDist := -1, 0
+			for p := 0; p+len(want) <= len(lines); p++ {
+				if !matchLines(lines[p:p+len(want)], want, eq) {
+					continue
+				}
+				dist := p - target
+				if after && dist < 0 {
+					dist = len(lines) - dist // any match before hint comes last
+				}
+				if dist < 0 {
+					dist = -dist
+				}
+				if best < 0 || dist < bestDist {
+					best, bestDist = p, dist
+				}
+			}
+			if best >= 0 {
+				return best, lead, trail, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+func matchLines(have, want []string, eq func(a, b string) bool) bool {
+	for i := range want {
+		if !eq(have[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func hunkMismatch(path string, n int, old []string) error {
+	shown := old[:min(len(old), 5)]
+	details := "expected these lines:\n" + strings.Join(shown, "\n")
+	if len(old) > len(shown) {
+		details += fmt.Sprintf("\n... (%d more)", len(old)-len(shown))
+	}
+	return NewToolErrorWithDetails(ErrHunkMismatch,
+		fmt.Sprintf("%s: hunk %d does not match the file. No files were changed; read the file and regenerate the patch.", path, n+1),
+		details)
+}
+
+// splitLines splits content into lines and reports whether it ends with a
+// newline. Empty content counts as ending with one, so that text added to it
+// does.
+func splitLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, true
+	}
+	lines := strings.Split(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1], true
+	}
+	return lines, false
+}
+
+func joinLines(lines []string, eol bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	s := strings.Join(lines, "\n")
+	if eol {
+		s += "\n"
+	}
+	return s
+}
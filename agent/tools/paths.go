@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-tui/config"
+)
+
+// resolvePath makes a tool path argument absolute.
+func resolvePath(path, workingDir string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.Join(workingDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// allowedPath resolves a tool path argument and checks it against the
+// workspace policy: after following symlinks it must lie inside the working
+// directory or one of the configured extra roots, and it must not be a
+// sensitive file. It returns the absolute path to use.
+func allowedPath(path, workingDir string) (string, error) {
+	abs := resolvePath(path, workingDir)
+	resolved := RealPath(abs)
+
+	if pattern, ok := sensitive(resolved); ok {
+		return "", NewToolErrorWithDetails(ErrSensitivePath, "access to this file is blocked",
+			fmt.Sprintf("%s matches the sensitive path %q", path, pattern))
+	}
+
+	roots := workspaceRoots(workingDir)
+	for _, root := range roots {
+		if within(root, resolved) {
+			return abs, nil
+		}
+	}
+	return "", NewToolErrorWithDetails(ErrPathOutsideWorkspace, "path is outside the workspace",
+		fmt.Sprintf("%s resolves to %s; allowed roots: %s", path, resolved, strings.Join(roots, ", ")))
+}
+
+// writablePath is allowedPath for tools that change files. The settings
+// directories are blocked as well: the permission rules and sandbox settings
+// in them must only change with the user's knowledge.
+func writablePath(path, workingDir string) (string, error) {
+	abs, err := allowedPath(path, workingDir)
+	if err != nil {
+		return "", err
+	}
+	resolved := RealPath(abs)
+	for _, dir := range []string{config.UserDir(), config.ProjectDir(workingDir)} {
+		if dir != "" && within(RealPath(dir), resolved) {
+			return "", NewToolErrorWithDetails(ErrSensitivePath, "writing to the settings directory is blocked",
+				fmt.Sprintf("%s is inside %s; ask the user to change settings and permissions", path, dir))
+		}
+	}
+	return abs, nil
+}
+
+// workspaceRoots returns the working directory, the extra roots from the
+// settings and the directory of saved command output, with symlinks resolved.
+func workspaceRoots(workingDir string) []string {
+	roots := []string{RealPath(workingDir)}
+	for _, r := range config.Current.Workspace.ExtraRoots {
+		roots = append(roots, RealPath(resolvePath(expandHome(r), workingDir)))
+	}
+	if dir := spillRoot(); dir != "" {
+		roots = append(roots, RealPath(dir))
+	}
+	return roots
+}
+
+// RealPath resolves the symlinks in path. Components that do not exist yet,
+// such as a file about to be written, are kept as they are.
+func RealPath(path string) string {
+	rest := ""
+	for p := path; ; p = filepath.Dir(p) {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if p == filepath.Dir(p) {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+	}
+}
+
+// sensitive reports the first sensitive pattern matching path.
+func sensitive(path string) (string, bool) {
+	for _, pattern := range config.Current.Workspace.Sensitive {
+		if strings.HasPrefix(pattern, "/") || strings.HasPrefix(pattern, "~/") {
+			if within(RealPath(expandHome(pattern)), path) {
+				return pattern, true
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// sensitiveNames returns the sensitive patterns that match file names, for
+// tools that must skip such files while walking a directory.
+func sensitiveNames() []string {
+	var names []string
+	for _, pattern := range config.Current.Workspace.Sensitive {
+		if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "~/") {
+			names = append(names, pattern)
+		}
+	}
+	return names
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
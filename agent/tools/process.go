@@ -0,0 +1,233 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go-tui/sandbox"
+)
+
+// Process is a command started by bash with run_in_background. It keeps
+// running across turns until it exits or is killed, and its output is
+// collected so bash_output can return it a piece at a time. Only output
+// that has not been returned yet is kept, and no more than
+// maxUnreadOutput of it.
+type Process struct {
+	ID      string
+	Command string
+	Started time.Time
+
+	cmd  *exec.Cmd
+	done chan struct{} // closed when the command has exited
+
+	mu      sync.Mutex
+	output  bytes.Buffer // stdout and stderr not returned yet
+	dropped int          // bytes of output dropped unread to stay within maxUnreadOutput
+	killed  bool
+	err     error // from Wait
+}
+
+// ProcessInfo is a snapshot of a background process, for display.
+type ProcessInfo struct {
+	ID      string
+	Command string
+	Started time.Time
+	Status  string // e.g. "running", "exited with code 1", "killed"
+}
+
+// Stopping a command sends SIGTERM to its process group and SIGKILL once
+// killGrace has passed. waitDelay bounds how long Wait then blocks on output
+// pipes still held open by children that escaped the group.
+const (
+	killGrace = 2 * time.Second
+	waitDelay = 2 * time.Second
+)
+
+// maxUnreadOutput is how much output of a background process is kept until
+// bash_output reads it; older output is dropped.
+const maxUnreadOutput = 1 << 20
+
+var (
+	processMu    sync.Mutex
+	processes    = map[string]*Process{}
+	processOrder []string
+)
+
+// startProcess runs command in the background, in the current directory of
+// the shell session, and adds it to the process table.
+func startProcess(command, workingDir string) (*Process, error) {
+	script := command
+	if dir := ShellDir(workingDir); dir != workingDir {
+		script = "cd " + shellQuote(dir) + " && " + command
+	}
+	cmd, err := sandbox.Command(script, workingDir)
+	if err != nil {
+		return nil, NewToolError(ErrSandboxUnavailable, err.Error())
+	}
+	p := &Process{Command: command, Started: time.Now(), cmd: cmd, done: make(chan struct{})}
+	cmd.Stdout = p
+	cmd.Stderr = p
+	prepareCommand(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting command: %w", err)
+	}
+
+	processMu.Lock()
+	p.ID = fmt.Sprintf("bg%d", len(processOrder)+1)
+	processes[p.ID] = p
+	processOrder = append(processOrder, p.ID)
+	processMu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// lookupProcess returns the background process with the given ID.
+func lookupProcess(id string) (*Process, error) {
+	processMu.Lock()
+	defer processMu.Unlock()
+	p, ok := processes[id]
+	if !ok {
+		return nil, NewToolError(ErrProcessNotFound, fmt.Sprintf("no background process %q", id))
+	}
+	return p, nil
+}
+
+// Write collects the command's output, dropping the oldest unread output
+// once there is more than maxUnreadOutput.
+func (p *Process) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.output.Write(b)
+	if over := p.output.Len() - maxUnreadOutput; over > 0 {
+		// Do not start in the middle of a UTF-8 sequence.
+		kept := p.output.Bytes()[over:]
+		for i := 0; i < utf8.UTFMax-1 && i < len(kept) && !utf8.RuneStart(kept[i]); i++ {
+			over++
+		}
+		p.output.Next(over)
+		p.dropped += over
+	}
+	return len(b), nil
+}
+
+// Running reports whether the command has not exited yet.
+func (p *Process) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Status describes whether the command is running or how it ended.
+func (p *Process) Status() string {
+	if p.Running() {
+		return "running"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var exitErr *exec.ExitError
+	switch {
+	case p.killed:
+		return "killed"
+	case p.err == nil:
+		return "exited with code 0"
+	case errors.As(p.err, &exitErr) && exitErr.ExitCode() >= 0:
+		return fmt.Sprintf("exited with code %d", exitErr.ExitCode())
+	default:
+		return p.err.Error()
+	}
+}
+
+// unread returns the output written since the last call and discards it.
+func (p *Process) unread() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.output.String()
+	if p.dropped > 0 {
+		out = fmt.Sprintf("[... %d bytes of earlier output dropped unread ...]\n", p.dropped) + out
+		p.dropped = 0
+	}
+	p.output.Reset()
+	return out
+}
+
+// Kill stops the command and waits for it to exit. It does nothing if the
+// command has already exited.
+func (p *Process) Kill() {
+	if !p.Running() {
+		return
+	}
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	stopCommand(p.cmd, p.done)
+}
+
+// Processes returns the background processes started this session, oldest
+// first.
+func Processes() []ProcessInfo {
+	processMu.Lock()
+	defer processMu.Unlock()
+	out := make([]ProcessInfo, 0, len(processOrder))
+	for _, id := range processOrder {
+		p := processes[id]
+		out = append(out, ProcessInfo{ID: p.ID, Command: p.Command, Started: p.Started, Status: p.Status()})
+	}
+	return out
+}
+
+// KillProcesses stops every background process that is still running.
+func KillProcesses() {
+	processMu.Lock()
+	running := make([]*Process, 0, len(processes))
+	for _, p := range processes {
+		running = append(running, p)
+	}
+	processMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range running {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Kill()
+		}()
+	}
+	wg.Wait()
+}
+
+// prepareCommand starts cmd in its own process group, so stopCommand reaches
+// every process it spawns, and keeps Wait from blocking forever on pipes.
+func prepareCommand(cmd *exec.Cmd) {
+	setProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+}
+
+// stopCommand terminates the process group of a started cmd, escalating to
+// SIGKILL if it has not exited after killGrace. exited must be closed when
+// Wait returns; stopCommand returns once it is.
+func stopCommand(cmd *exec.Cmd, exited <-chan struct{}) {
+	terminateGroup(cmd)
+	select {
+	case <-exited:
+		// Children that outlived the shell are still in the group.
+		killGroup(cmd)
+	case <-time.After(killGrace):
+		killGroup(cmd)
+		<-exited
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !unix
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing: process groups are a Unix feature, so only
+// the command itself is stopped on this platform.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateGroup(cmd *exec.Cmd) { killGroup(cmd) }
+
+func killGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// exitSignal returns "": processes are not ended by signals on this platform.
+func exitSignal(state *os.ProcessState) string { return "" }
+
+// processGroups reports that process groups cannot be listed on this platform.
+func processGroups(pid int) ([]int, bool) { return nil, false }
+
+func signalGroups(groups []int, kill bool) {}
+
+func notifyGroups(groups []int) {}
+
+func statusSignal(status int) string { return "" }
@@ -0,0 +1,106 @@
+//go:build unix
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in its own process group, so that it and every
+// child it spawns can be signalled together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup sends sig to the process group of cmd, or to cmd alone if it
+// was not started in its own group.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) {
+	if cmd.Process == nil {
+		return
+	}
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		syscall.Kill(-cmd.Process.Pid, sig)
+		return
+	}
+	cmd.Process.Signal(sig)
+}
+
+func terminateGroup(cmd *exec.Cmd) { signalGroup(cmd, syscall.SIGTERM) }
+
+func killGroup(cmd *exec.Cmd) { signalGroup(cmd, syscall.SIGKILL) }
+
+// exitSignal returns the name of the signal that killed the process, or "".
+func exitSignal(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal().String()
+	}
+	return ""
+}
+
+// processGroups returns the process groups, other than its own, of the
+// processes pid started, directly or not. ok is false if the process table
+// could not be read.
+func processGroups(pid int) (groups []int, ok bool) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,pgid=").Output()
+	if err != nil {
+		return nil, false
+	}
+	children := map[int][]int{}
+	group := map[int]int{}
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 {
+			continue
+		}
+		p, err1 := strconv.Atoi(f[0])
+		parent, err2 := strconv.Atoi(f[1])
+		g, err3 := strconv.Atoi(f[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		children[parent] = append(children[parent], p)
+		group[p] = g
+	}
+	queue := children[pid]
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = append(queue[1:], children[p]...)
+		if g := group[p]; g != pid && !slices.Contains(groups, g) {
+			groups = append(groups, g)
+		}
+	}
+	return groups, true
+}
+
+// signalGroups sends SIGTERM, or SIGKILL if kill is set, to the process groups.
+func signalGroups(groups []int, kill bool) {
+	sig := syscall.SIGTERM
+	if kill {
+		sig = syscall.SIGKILL
+	}
+	for _, g := range groups {
+		syscall.Kill(-g, sig)
+	}
+}
+
+// notifyGroups sends SIGWINCH, which processes ignore unless they handle it,
+// to the process groups.
+func notifyGroups(groups []int) {
+	for _, g := range groups {
+		syscall.Kill(-g, syscall.SIGWINCH)
+	}
+}
+
+// statusSignal returns the name of the signal that an exit status of 128+n,
+// as bash reports it, says ended a command, or "".
+func statusSignal(status int) string {
+	if status > 128 && status < 128+32 {
+		return syscall.Signal(status - 128).String()
+	}
+	return ""
+}
@@ -1,10 +1,10 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"go-tui/config"
@@ -40,18 +40,27 @@ func init() {
 			},
 			"required": ["file_path"]
 		}`),
-		Run: executeReadFile,
+		ToolInfo: Info{
+			Category:   CategoryRead,
+			Icon:       config.ReadIcon,
+			ReadOnly:   true,
+			Permission: PermissionAllow,
+			RuleArg:    "file_path",
+			RuleKind:   RulePath,
+		},
+		ToolFormat: formatReadFile,
+		Run:        executeReadFile,
 	})
 }
 
-func executeReadFile(args ReadFileArgs, workingDir string) (ToolResult, error) {
+func executeReadFile(ctx context.Context, args ReadFileArgs, workingDir string) (ToolResult, error) {
 	if args.FilePath == "" {
 		return ToolResult{}, NewToolError(ErrMissingField, "file_path is required")
 	}
 
-	path := args.FilePath
-	if !filepath.IsAbs(path) {
-		path = filepath.Join(workingDir, path)
+	path, err := allowedPath(args.FilePath, workingDir)
+	if err != nil {
+		return ToolResult{}, err
 	}
 
 	data, err := os.ReadFile(path)
@@ -90,3 +99,16 @@ func executeReadFile(args ReadFileArgs, workingDir string) (ToolResult, error) {
 
 	return ToolResult{Output: sb.String()}, nil
 }
+
+func formatReadFile(args ReadFileArgs) string {
+	s := "Read: " + args.FilePath
+	switch {
+	case args.Offset > 0 && args.Limit > 0:
+		s += fmt.Sprintf(" %d:%d", args.Offset, args.Offset+args.Limit-1)
+	case args.Offset > 0:
+		s += fmt.Sprintf(" from %d", args.Offset)
+	case args.Limit > 0:
+		s += fmt.Sprintf(" first %d lines", args.Limit)
+	}
+	return s
+}
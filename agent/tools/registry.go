@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 
 	"go-tui/llm"
@@ -26,10 +27,35 @@ func All() []llm.Tool {
 	return out
 }
 
-func Execute(name string, argsJSON string, workingDir string) (ToolResult, error) {
+// Select returns the wire schemas of the named tools, in registration order.
+// Unknown names are reported as an error.
+func Select(names []string) ([]llm.Tool, error) {
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := registry[name]; !ok {
+			return nil, fmt.Errorf("unknown tool: %s", name)
+		}
+		want[name] = true
+	}
+	out := make([]llm.Tool, 0, len(names))
+	for _, name := range registryOrder {
+		if want[name] {
+			out = append(out, ToLLMTool(registry[name]))
+		}
+	}
+	return out, nil
+}
+
+// IsReadOnly reports whether the named tool is registered and read-only.
+func IsReadOnly(name string) bool {
+	t, ok := registry[name]
+	return ok && t.Info().ReadOnly
+}
+
+func Execute(ctx context.Context, name string, argsJSON string, workingDir string) (ToolResult, error) {
 	t, ok := registry[name]
 	if !ok {
 		return ToolResult{}, fmt.Errorf("unknown tool: %s", name)
 	}
-	return t.Execute(argsJSON, workingDir)
+	return t.Execute(ctx, argsJSON, workingDir)
 }
@@ -1,118 +1,447 @@
 package tools
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"go-tui/config"
+)
+
+const (
+	searchDefaultLimit = 100      // result lines returned when the call sets no limit
+	searchMaxLineBytes = 500      // longer lines, e.g. minified code, are cut
+	searchMaxFileBytes = 10 << 20 // larger files are skipped by the built-in searcher
+	searchBinarySniff  = 8 << 10  // bytes checked for NUL to detect binary files
+)
+
+// Output modes of the search tool.
+const (
+	searchContent = "content" // matching lines, with context if requested
+	searchFiles   = "files"   // paths of files with a match
+	searchCount   = "count"   // number of matching lines per file
 )
 
 type SearchArgs struct {
-	Pattern string `json:"pattern"`
-	Path    string `json:"path"`
+	Pattern         string   `json:"pattern"`
+	Path            string   `json:"path"`
+	Literal         bool     `json:"literal,omitempty"`
+	CaseInsensitive bool     `json:"case_insensitive,omitempty"`
+	Include         []string `json:"include,omitempty"`
+	Exclude         []string `json:"exclude,omitempty"`
+	Context         int      `json:"context,omitempty"`
+	OutputMode      string   `json:"output_mode,omitempty"`
+	Offset          int      `json:"offset,omitempty"`
+	Limit           int      `json:"limit,omitempty"`
 }
 
 func init() {
 	Register(Typed[SearchArgs]{
 		ToolName:        "search",
-		ToolDescription: "Search for a string pattern in files using grep. Returns matching lines with file paths and line numbers.",
+		ToolDescription: "Search file contents for a regular expression (or literal text) and return matching lines as path:line:text. Respects .gitignore. Use include/exclude globs to narrow the files, context for surrounding lines, output_mode \"files\" or \"count\" for an overview, and offset/limit to page through many results.",
 		ToolSchema: json.RawMessage(`{
 			"type": "object",
 			"properties": {
 				"pattern": {
 					"type": "string",
-					"description": "The string pattern to search for"
+					"description": "Regular expression to search for (RE2 syntax), or literal text if literal is set"
 				},
 				"path": {
 					"type": "string",
 					"description": "Directory or file path to search in (default: current directory)"
+				},
+				"literal": {
+					"type": "boolean",
+					"description": "Treat pattern as literal text instead of a regular expression"
+				},
+				"case_insensitive": {
+					"type": "boolean",
+					"description": "Ignore case when matching"
+				},
+				"include": {
+					"type": "array",
+					"items": {"type": "string"},
+					"description": "Only search files matching one of these globs, e.g. [\"*.go\", \"src/**/*.ts\"]. Globs without a slash match file names"
+				},
+				"exclude": {
+					"type": "array",
+					"items": {"type": "string"},
+					"description": "Skip files and directories matching one of these globs, e.g. [\"*_test.go\", \"testdata\"]"
+				},
+				"context": {
+					"type": "integer",
+					"description": "Lines of context to show before and after each match (content mode only)"
+				},
+				"output_mode": {
+					"type": "string",
+					"enum": ["content", "files", "count"],
+					"description": "content: matching lines (default); files: paths of matching files; count: matches per file"
+				},
+				"offset": {
+					"type": "integer",
+					"description": "Number of result lines to skip, to page through results"
+				},
+				"limit": {
+					"type": "integer",
+					"description": "Maximum number of result lines to return (default 100)"
 				}
 			},
 			"required": ["pattern"]
 		}`),
-		Run: executeSearch,
+		ToolInfo: Info{
+			Category:   CategorySearch,
+			Icon:       config.SearchIcon,
+			ReadOnly:   true,
+			Permission: PermissionAllow,
+			RuleArg:    "path",
+			RuleKind:   RulePath,
+		},
+		ToolFormat: formatSearch,
+		Run:        executeSearch,
 	})
 }
 
-func executeSearch(args SearchArgs, workingDir string) (ToolResult, error) {
+func executeSearch(ctx context.Context, args SearchArgs, workingDir string) (ToolResult, error) {
 	if args.Pattern == "" {
 		return ToolResult{}, NewToolError(ErrMissingField, "pattern is required")
 	}
-
-	searchPath := args.Path
-	if searchPath == "" {
-		searchPath = workingDir
-	} else if !filepath.IsAbs(searchPath) {
-		searchPath = filepath.Join(workingDir, searchPath)
+	if args.OutputMode == "" {
+		args.OutputMode = searchContent
+	}
+	switch {
+	case args.OutputMode != searchContent && args.OutputMode != searchFiles && args.OutputMode != searchCount:
+		return ToolResult{}, NewToolError(ErrInvalidArguments, fmt.Sprintf("unknown output_mode %q", args.OutputMode))
+	case args.Context < 0 || args.Offset < 0 || args.Limit < 0:
+		return ToolResult{}, NewToolError(ErrInvalidArguments, "context, offset and limit must not be negative")
+	}
+	if args.Limit == 0 {
+		args.Limit = searchDefaultLimit
 	}
 
+	searchPath, err := allowedPath(args.Path, workingDir)
+	if err != nil {
+		return ToolResult{}, err
+	}
 	if _, err := os.Stat(searchPath); os.IsNotExist(err) {
 		return ToolResult{}, NewToolErrorWithDetails(ErrFileNotFound, "path does not exist", args.Path)
 	}
 
-	cmd := exec.Command("grep",
-		"-rn",
-		"--include=*.go",
-		"--include=*.js",
-		"--include=*.ts",
-		"--include=*.tsx",
-		"--include=*.jsx",
-		"--include=*.py",
-		"--include=*.rs",
-		"--include=*.java",
-		"--include=*.c",
-		"--include=*.h",
-		"--include=*.cpp",
-		"--include=*.md",
-		"--include=*.txt",
-		"--include=*.yaml",
-		"--include=*.yml",
-		"--include=*.toml",
-		"--include=*.json",
-		"--include=*.html",
-		"--include=*.css",
-		"--include=*.sh",
-		"--include=*.mod",
-		"--include=*.sum",
-		"--exclude-dir=.git",
-		"--exclude-dir=node_modules",
-		"--exclude-dir=vendor",
-		"--exclude-dir=log",
-		"--exclude-dir=conversations",
-		args.Pattern,
-		searchPath,
-	)
+	// Validate the pattern and globs here so both searchers report the same
+	// errors.
+	re, err := searchRegexp(args)
+	if err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrInvalidArguments, "invalid pattern", err.Error())
+	}
+	include, err := compileGlobs(args.Include)
+	if err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrInvalidArguments, "invalid include glob", err.Error())
+	}
+	exclude, err := compileGlobs(args.Exclude)
+	if err != nil {
+		return ToolResult{}, NewToolErrorWithDetails(ErrInvalidArguments, "invalid exclude glob", err.Error())
+	}
+
+	var lines []string
+	if rg, err := exec.LookPath("rg"); err == nil {
+		lines, err = searchRipgrep(ctx, rg, args, searchPath, workingDir)
+		if err != nil {
+			return ToolResult{}, err
+		}
+	} else {
+		s := fileSearcher{re: re, args: args, include: include, exclude: exclude, workingDir: workingDir}
+		if lines, err = s.search(ctx, searchPath); err != nil {
+			return ToolResult{}, err
+		}
+	}
+	if len(lines) == 0 {
+		return ToolResult{Output: "No matches found."}, nil
+	}
+	return ToolResult{Output: pageLines(lines, args.Offset, args.Limit)}, nil
+}
+
+// searchRegexp compiles the pattern for the built-in searcher.
+func searchRegexp(args SearchArgs) (*regexp.Regexp, error) {
+	pattern := args.Pattern
+	if args.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if args.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// pathGlob is an include or exclude glob of the search tool.
+type pathGlob struct {
+	re     *regexp.Regexp
+	byName bool // without a slash, the glob matches the file name
+}
+
+func compileGlobs(globs []string) ([]pathGlob, error) {
+	out := make([]pathGlob, 0, len(globs))
+	for _, g := range globs {
+		re, err := compileGlob(strings.TrimPrefix(g, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", g, err)
+		}
+		out = append(out, pathGlob{re: re, byName: !strings.Contains(g, "/")})
+	}
+	return out, nil
+}
+
+// matchGlobs reports whether rel, a slash-separated path relative to the
+// search root, matches any of globs.
+func matchGlobs(globs []pathGlob, rel string) bool {
+	for _, g := range globs {
+		subject := rel
+		if g.byName {
+			subject = filepath.Base(rel)
+		}
+		if g.re.MatchString(subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchRipgrep runs rg with the equivalent of the call's options.
+func searchRipgrep(ctx context.Context, rg string, args SearchArgs, searchPath, workingDir string) ([]string, error) {
+	rgArgs := []string{
+		"--no-heading", "--with-filename", "--line-number", "--color=never",
+		"--no-messages", "--hidden", "--no-require-git", "--sort=path",
+		"--glob=!.git", "--null",
+	}
+	if args.Literal {
+		rgArgs = append(rgArgs, "--fixed-strings")
+	}
+	if args.CaseInsensitive {
+		rgArgs = append(rgArgs, "--ignore-case")
+	}
+	switch args.OutputMode {
+	case searchFiles:
+		rgArgs = append(rgArgs, "--files-with-matches")
+	case searchCount:
+		rgArgs = append(rgArgs, "--count")
+	default:
+		if args.Context > 0 {
+			rgArgs = append(rgArgs, "--context="+strconv.Itoa(args.Context))
+		}
+	}
+	for _, g := range args.Include {
+		rgArgs = append(rgArgs, "--glob="+g)
+	}
+	for _, g := range args.Exclude {
+		rgArgs = append(rgArgs, "--glob=!"+g)
+	}
+	// Never show the contents of sensitive files such as .env. Patterns for
+	// paths, such as ~/.ssh, are applied to the output.
+	for _, name := range sensitiveNames() {
+		rgArgs = append(rgArgs, "--glob=!"+name)
+	}
+
+	// Search relative to the working directory so rg prints relative paths.
+	target := searchPath
+	if rel, err := filepath.Rel(workingDir, searchPath); err == nil && !strings.HasPrefix(rel, "..") {
+		target = rel
+	}
+	rgArgs = append(rgArgs, "--", args.Pattern, target)
 
+	cmd := exec.CommandContext(ctx, rg, rgArgs...)
+	cmd.Dir = workingDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return nil, nil // no matches
+	case len(output) == 0:
+		return nil, fmt.Errorf("rg failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return ripgrepLines(string(output), args.OutputMode, workingDir), nil
+}
+
+// ripgrepLines turns the output of rg --null into path:line:text lines,
+// leaving out those of sensitive files, as the built-in searcher does.
+func ripgrepLines(output, mode, workingDir string) []string {
+	var lines []string
+	if mode == searchFiles {
+		// Paths end with NUL instead of a newline.
+		for _, path := range strings.Split(strings.TrimSuffix(output, "\x00"), "\x00") {
+			if _, blocked := sensitive(resolvePath(path, workingDir)); !blocked {
+				lines = append(lines, truncateLine(strings.TrimPrefix(path, "./")))
+			}
+		}
+		return lines
+	}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		path, rest, ok := strings.Cut(line, "\x00")
+		if !ok {
+			lines = append(lines, line) // "--" between context groups
+			continue
+		}
+		if _, blocked := sensitive(resolvePath(path, workingDir)); blocked {
+			continue
+		}
+		// The NUL stands for the separator after the path: ':' for a
+		// match or count, '-' for a context line, as after the line number.
+		sep := ":"
+		if i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' }); i > 0 {
+			sep = rest[i : i+1]
+		}
+		lines = append(lines, truncateLine(strings.TrimPrefix(path, "./")+sep+rest))
+	}
+	return lines
+}
+
+// fileSearcher is the built-in searcher used when rg is not installed. Its
+// output has the same format as rg's.
+type fileSearcher struct {
+	re         *regexp.Regexp
+	args       SearchArgs
+	include    []pathGlob
+	exclude    []pathGlob
+	workingDir string
+
+	lines []string
+}
+
+func (s *fileSearcher) search(ctx context.Context, root string) ([]string, error) {
+	info, err := os.Stat(root)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			if exitErr.ExitCode() == 1 {
-				return ToolResult{Output: "No matches found."}, nil
+		return nil, err
+	}
+	if !info.IsDir() {
+		s.searchFile(root)
+		return s.lines, nil
+	}
+	err = walkTree(ctx, root, s.workingDir, func(path string, d fs.DirEntry, depth int) error {
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if matchGlobs(s.exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
+		}
+		if d.Type().IsRegular() && (len(s.include) == 0 || matchGlobs(s.include, rel)) {
+			s.searchFile(path)
 		}
-		return ToolResult{}, fmt.Errorf("grep failed: %v", err)
+		return nil
+	})
+	return s.lines, err
+}
+
+func (s *fileSearcher) searchFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > searchMaxFileBytes {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data[:min(len(data), searchBinarySniff)], 0) >= 0 {
+		return
+	}
+	name := path
+	if rel, err := filepath.Rel(s.workingDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		name = filepath.ToSlash(rel)
 	}
 
-	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	matched := make([]bool, len(lines))
+	count := 0
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+		if s.re.MatchString(lines[i]) {
+			matched[i] = true
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+
+	switch s.args.OutputMode {
+	case searchFiles:
+		s.lines = append(s.lines, name)
+		return
+	case searchCount:
+		s.lines = append(s.lines, fmt.Sprintf("%s:%d", name, count))
+		return
+	}
 
+	// Print matches as name:line:text and context lines as name-line-text,
+	// separating blocks that are not adjacent with "--" like rg does.
+	n := s.args.Context
+	shown := make([]bool, len(lines))
+	for i := range lines {
+		if matched[i] {
+			for j := max(i-n, 0); j <= min(i+n, len(lines)-1); j++ {
+				shown[j] = true
+			}
+		}
+	}
+	last := -2
 	for i, line := range lines {
-		if rel, err := filepath.Rel(workingDir, line); err == nil {
-			lines[i] = rel
-		} else if strings.HasPrefix(line, workingDir) {
-			lines[i] = strings.TrimPrefix(line, workingDir+"/")
+		if !shown[i] {
+			continue
 		}
-		if len(lines[i]) > 200 {
-			lines[i] = lines[i][:200] + "..."
+		if n > 0 && len(s.lines) > 0 && i != last+1 {
+			s.lines = append(s.lines, "--")
 		}
+		sep := "-"
+		if matched[i] {
+			sep = ":"
+		}
+		s.lines = append(s.lines, truncateLine(fmt.Sprintf("%s%s%d%s%s", name, sep, i+1, sep, line)))
+		last = i
+	}
+}
+
+// truncateLine cuts a result line that is too long to be useful.
+func truncateLine(line string) string {
+	if len(line) <= searchMaxLineBytes {
+		return line
 	}
+	return truncateUTF8(line, searchMaxLineBytes) + "…"
+}
 
+// pageLines returns limit lines starting at offset, with a note on how to get
+// the rest.
+func pageLines(lines []string, offset, limit int) string {
 	total := len(lines)
-	if total > 30 {
-		lines = lines[:30]
-		lines = append(lines, fmt.Sprintf("... (%d total matches, showing first 30)", total))
+	if offset >= total {
+		return fmt.Sprintf("No results at offset %d (%d in total).", offset, total)
+	}
+	end := min(offset+limit, total)
+	out := strings.Join(lines[offset:end], "\n")
+	if offset > 0 || end < total {
+		out += fmt.Sprintf("\n... (results %d-%d of %d", offset+1, end, total)
+		if end < total {
+			out += fmt.Sprintf("; pass offset %d for more", end)
+		}
+		out += ")"
 	}
+	return out
+}
 
-	return ToolResult{Output: strings.Join(lines, "\n")}, nil
+func formatSearch(args SearchArgs) string {
+	s := "Search: " + args.Pattern
+	if args.Path != "" {
+		s += " in " + args.Path
+	}
+	return s
 }
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	"go-tui/config"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Sensitive paths given as absolute or home-relative patterns are left out
+// by both searchers, in the working directory and in extra roots alike.
+func TestSearchSkipsSensitivePaths(t *testing.T) {
+	rg, err := exec.LookPath("rg")
+	if err != nil {
+		t.Skip("rg is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workingDir := filepath.Join(home, "project")
+	extra := filepath.Join(home, "shared")
+	writeFiles(t, workingDir, map[string]string{
+		"main.go":          "token := 1\n",
+		"secrets/prod.txt": "token: prod\n",
+		"notes/a:b.txt":    "context\ntoken here\n",
+	})
+	writeFiles(t, extra, map[string]string{
+		"lib.go":       "token := 2\n",
+		"keys/id.pem":  "token\n",
+		"keys/sub/key": "token\n",
+	})
+
+	saved := config.Current
+	t.Cleanup(func() { config.Current = saved })
+	settings := *saved
+	settings.Workspace.ExtraRoots = []string{extra}
+	settings.Workspace.Sensitive = []string{filepath.Join(workingDir, "secrets"), "~/shared/keys"}
+	config.Current = &settings
+
+	tests := []struct {
+		root string
+		mode string
+		want []string
+	}{
+		{workingDir, searchContent, []string{"main.go:1:token := 1", "notes/a:b.txt:2:token here"}},
+		{workingDir, searchFiles, []string{"main.go", "notes/a:b.txt"}},
+		{workingDir, searchCount, []string{"main.go:1", "notes/a:b.txt:1"}},
+		{extra, searchContent, []string{extra + "/lib.go:1:token := 2"}},
+	}
+	for _, tt := range tests {
+		args := SearchArgs{Pattern: "token", OutputMode: tt.mode}
+		got, err := searchRipgrep(context.Background(), rg, args, tt.root, workingDir)
+		if err != nil {
+			t.Fatalf("rg: %v", err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("rg in %s, %s: got %q, want %q", tt.root, tt.mode, got, tt.want)
+		}
+
+		s := fileSearcher{re: regexp.MustCompile("token"), args: args, workingDir: workingDir}
+		got, err = s.search(context.Background(), tt.root)
+		if err != nil {
+			t.Fatalf("built-in: %v", err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("built-in in %s, %s: got %q, want %q", tt.root, tt.mode, got, tt.want)
+		}
+	}
+}
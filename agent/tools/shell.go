@@ -0,0 +1,343 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-tui/sandbox"
+)
+
+// shellSession is a long-lived bash process that runs the foreground bash
+// commands of a conversation one after another, so the directory, variables
+// and functions set by one command are still there for the next.
+//
+// Each command is sourced from a here-document with stdin from /dev/null and
+// followed by a line starting with a marker unique to the command, which also
+// carries the exit status and working directory. Output before the marker belongs to the
+// command. stdout and stderr share one pipe, so the output is in the order
+// the command wrote it.
+//
+// The shell runs with job control on, which gives every command it starts a
+// process group of its own. A command that times out or is interrupted is
+// stopped through its groups, and SIGWINCH makes the shell return from the
+// rest of it, leaving the shell and its state alone.
+type shellSession struct {
+	root     string // working directory the session was started for
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	exited   chan struct{} // closed once the shell has exited
+	sentinel string
+
+	mu      sync.Mutex
+	cwd     string
+	seq     int
+	marker  string           // marker of the running command
+	out     *strings.Builder // output of the running command; nil between commands
+	pending string           // the unfinished last line
+	done    bool             // whether the marker line of the running command arrived
+	trailer string           // the rest of the marker line
+	changed chan struct{}    // signalled when the marker line arrives
+}
+
+// maxPendingLine is how much of an unfinished line is held back before it
+// is passed on anyway.
+const maxPendingLine = 64 * 1024
+
+// shellWaitDelay is how long output is still read once the shell has
+// exited. Jobs that outlive it may hold the pipe open indefinitely.
+const shellWaitDelay = 200 * time.Millisecond
+
+// interruptPoll is how often an interrupted command's process groups are
+// looked up and signalled again.
+const interruptPoll = 100 * time.Millisecond
+
+// sessionRestartNote tells the model what was lost when a session ends.
+const sessionRestartNote = "the shell session ended; the next command starts a new one in %s without the variables and functions set so far"
+
+var (
+	shellRunMu sync.Mutex // serializes commands
+	shellMu    sync.Mutex // guards shell
+	shell      *shellSession
+)
+
+// runInShell runs command in the conversation's shell session, starting one
+// in workingDir if there is none yet.
+func runInShell(ctx context.Context, command, workingDir string, timeout time.Duration) (BashResult, error) {
+	shellRunMu.Lock()
+	defer shellRunMu.Unlock()
+
+	shellMu.Lock()
+	s := shell
+	shellMu.Unlock()
+	if s != nil && (!s.alive() || s.root != workingDir) {
+		s.stop()
+		s = nil
+	}
+	if s == nil {
+		var err error
+		if s, err = startShell(workingDir); err != nil {
+			return BashResult{}, err
+		}
+		shellMu.Lock()
+		shell = s
+		shellMu.Unlock()
+	}
+	return s.run(ctx, command, timeout)
+}
+
+// ResetShell stops the shell session, interrupting any command it is running.
+// The next bash command starts a new one in the working directory.
+func ResetShell() {
+	shellMu.Lock()
+	s := shell
+	shell = nil
+	shellMu.Unlock()
+	if s != nil {
+		s.stop()
+	}
+}
+
+// ShellDir returns the current directory of the shell session, or workingDir
+// if no session is running for it.
+func ShellDir(workingDir string) string {
+	shellMu.Lock()
+	s := shell
+	shellMu.Unlock()
+	if s == nil || s.root != workingDir || !s.alive() {
+		return workingDir
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cwd
+}
+
+func startShell(workingDir string) (*shellSession, error) {
+	cmd, err := sandbox.Command("exec bash --noprofile --norc", workingDir)
+	if err != nil {
+		return nil, NewToolError(ErrSandboxUnavailable, err.Error())
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	prepareCommand(cmd)
+	cmd.WaitDelay = shellWaitDelay
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	s := &shellSession{
+		root:     workingDir,
+		cmd:      cmd,
+		stdin:    stdin,
+		exited:   make(chan struct{}),
+		sentinel: "__go_tui_" + hex.EncodeToString(id),
+		cwd:      workingDir,
+		changed:  make(chan struct{}, 1),
+	}
+	cmd.Stdout = s
+	cmd.Stderr = s
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting shell: %w", err)
+	}
+	go func() {
+		cmd.Wait()
+		s.mu.Lock()
+		s.emit(s.pending)
+		s.pending = ""
+		s.mu.Unlock()
+		close(s.exited)
+	}()
+	// Wait for the setup to be done before the first command runs. The
+	// trap keeps the status of a command that was killed and otherwise
+	// reports 130, as for Ctrl-C.
+	setup := "set -m; trap 'return $(( $? > 128 ? $? : 130 )) 2>/dev/null' WINCH"
+	if _, err := s.run(context.Background(), setup, killGrace); err != nil || !s.finished() {
+		s.stop()
+		return nil, fmt.Errorf("starting shell: no response")
+	}
+	return s, nil
+}
+
+// run sends command to the shell and waits for its marker line.
+func (s *shellSession) run(ctx context.Context, command string, timeout time.Duration) (BashResult, error) {
+	// The groups already there, the shell's own and those of jobs earlier
+	// commands left running with &, are spared if the command is stopped.
+	spare, _ := processGroups(s.cmd.Process.Pid)
+
+	out := &strings.Builder{}
+	s.mu.Lock()
+	s.seq++
+	s.marker = fmt.Sprintf("%s_%d:", s.sentinel, s.seq)
+	s.out = out
+	s.done, s.trailer = false, ""
+	s.mu.Unlock()
+
+	var script strings.Builder
+	end := s.marker[:len(s.marker)-1] + "_end"
+	fmt.Fprintf(&script, ". /dev/fd/3 3<<'%s' </dev/null\n%s\n%s\n", end, command, end)
+	fmt.Fprintf(&script, "printf '%%s%%d %%s\\n' %s \"$?\" \"$PWD\"\n", shellQuote(s.marker))
+
+	start := time.Now()
+	result := BashResult{}
+	if _, err := io.WriteString(s.stdin, script.String()); err != nil {
+		return BashResult{}, fmt.Errorf("writing to shell: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+wait:
+	for !s.finished() {
+		select {
+		case <-s.changed:
+		case <-s.exited:
+			// The command ended the shell, e.g. with exit or set -e.
+			result.ExitCode = s.cmd.ProcessState.ExitCode()
+			result.Signal = exitSignal(s.cmd.ProcessState)
+			result.Note = fmt.Sprintf(sessionRestartNote, s.root)
+			break wait
+		case <-timer.C:
+			result.TimedOut = true
+			if !s.interrupt(spare) {
+				result.ExitCode = s.cmd.ProcessState.ExitCode()
+				result.Signal = exitSignal(s.cmd.ProcessState)
+				result.Note = fmt.Sprintf(sessionRestartNote, s.root)
+				break wait
+			}
+		case <-ctx.Done():
+			s.interrupt(spare)
+			return BashResult{}, ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	if status, dir, ok := strings.Cut(s.trailer, " "); s.done && ok {
+		result.ExitCode, _ = strconv.Atoi(status)
+		result.Signal = statusSignal(result.ExitCode)
+		s.cwd = dir
+	}
+	s.out = nil
+	result.Output = out.String()
+	s.mu.Unlock()
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// finished reports whether the marker line of the running command arrived.
+func (s *shellSession) finished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// Write passes the output of the shell, stdout and stderr alike, to the
+// running command.
+func (s *shellSession) Write(b []byte) (int, error) {
+	s.receive(string(b))
+	return len(b), nil
+}
+
+// receive handles a chunk of output. Complete lines are passed on as they
+// arrive; the last, unfinished line is held back in case the marker follows.
+func (s *shellSession) receive(chunk string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.pending + chunk
+	if s.marker != "" {
+		if i := strings.Index(p, s.marker); i >= 0 {
+			end := strings.IndexByte(p[i:], '\n')
+			if end < 0 {
+				s.pending = p
+				return
+			}
+			s.emit(p[:i])
+			s.done, s.trailer = true, p[i+len(s.marker):i+end]
+			s.pending = p[i+end+1:]
+			select {
+			case s.changed <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+	if i := strings.LastIndexByte(p, '\n'); i >= 0 {
+		s.emit(p[:i+1])
+		p = p[i+1:]
+	} else if keep := len(s.marker); len(p) > maxPendingLine && len(p) > keep {
+		// A very long line, e.g. a progress bar redrawn with \r.
+		s.emit(p[:len(p)-keep])
+		p = p[len(p)-keep:]
+	}
+	s.pending = p
+}
+
+// emit adds text to the output of the running command. Output that arrives
+// between commands, e.g. from a job left running with &, is dropped.
+func (s *shellSession) emit(text string) {
+	if s.out != nil && !s.done {
+		s.out.WriteString(text)
+	}
+}
+
+func (s *shellSession) alive() bool {
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// interrupt stops the running command, sending SIGTERM to the process
+// groups it started and SIGKILL once killGrace has passed, and SIGWINCH to
+// the shell so that it skips the rest of the command. Both are sent again
+// until the command finishes, as a function only returns itself and a loop
+// may start new processes first. If it does not finish, e.g. because it
+// changed the trap, the session is stopped instead. interrupt reports
+// whether the session survived.
+func (s *shellSession) interrupt(spare []int) bool {
+	start := time.Now()
+	for !s.finished() {
+		groups, ok := processGroups(s.cmd.Process.Pid)
+		if !ok || time.Since(start) > 2*killGrace {
+			s.stop()
+			return false
+		}
+		notifyGroups(append(groups, s.cmd.Process.Pid))
+		groups = slices.DeleteFunc(groups, func(g int) bool { return slices.Contains(spare, g) })
+		signalGroups(groups, time.Since(start) > killGrace)
+		select {
+		case <-s.changed:
+		case <-s.exited:
+			return false
+		case <-time.After(interruptPoll):
+		}
+	}
+	return true
+}
+
+// stop ends the shell and every process it started.
+func (s *shellSession) stop() {
+	if !s.alive() {
+		return
+	}
+	// Jobs are in process groups of their own.
+	groups, _ := processGroups(s.cmd.Process.Pid)
+	signalGroups(groups, false)
+	stopCommand(s.cmd, s.exited)
+	signalGroups(groups, true)
+}
+
+// shellQuote quotes s as a single word for bash.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,74 @@
+//go:build unix
+
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// A job that outlives the shell keeps its output pipe open; the session must
+// still notice at once that the shell exited.
+func TestShellExitWithOrphanedJob(t *testing.T) {
+	s, err := startShell(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.stop()
+
+	start := time.Now()
+	result, err := s.run(context.Background(), "echo bye; (sleep 60 &); exit 3", 30*time.Second)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %s after the shell exited", elapsed)
+	}
+	if result.ExitCode != 3 || result.Output != "bye\n" || result.Note == "" {
+		t.Errorf("result = %+v, want exit code 3, the output and a restart note", result)
+	}
+	if s.alive() {
+		t.Error("session still alive after exit")
+	}
+}
+
+func TestShellStopWithOrphanedJob(t *testing.T) {
+	s, err := startShell(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.run(context.Background(), "(sleep 60 &)", 30*time.Second); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	start := time.Now()
+	s.stop()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stop took %s", elapsed)
+	}
+}
+
+func TestShellCancelWithOrphanedJob(t *testing.T) {
+	s, err := startShell(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.run(ctx, "(sleep 60 &); sleep 60", 30*time.Second); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelling took %s", elapsed)
+	}
+
+	// The session survives and runs the next command.
+	result, err := s.run(context.Background(), "echo ok", 30*time.Second)
+	if err != nil || result.Output != "ok\n" {
+		t.Errorf("next command: %+v, %v", result, err)
+	}
+}
@@ -1,15 +1,19 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	"go-tui/llm"
 )
 
-// ToolResult replaces bare string returns from tool execution.
+// ToolResult replaces bare string returns from tool execution. Output is
+// what the model sees; Changes, when set, are the file changes the call made,
+// for display only.
 type ToolResult struct {
-	Output string
+	Output  string
+	Changes []FileChange
 }
 
 // ToolImpl is the non-generic interface so the registry can hold []ToolImpl.
@@ -17,7 +21,20 @@ type ToolImpl interface {
 	Name() string
 	Description() string
 	Schema() json.RawMessage
-	Execute(argsJSON string, workingDir string) (ToolResult, error)
+	Info() Info
+	// Format returns a one-line description of a call for display, without
+	// the icon, e.g. "Read: main.go".
+	Format(argsJSON string) string
+	// Changes returns the file changes a call makes. With an empty result it
+	// previews them from the arguments, before the call runs. Tools whose
+	// result does not carry the changed text return them in
+	// ToolResult.Changes instead, and nil here once the call has run.
+	Changes(argsJSON, result, workingDir string) []FileChange
+	// Paths returns the files a call touches, for tools that take several
+	// and so have no single RuleArg. Permission rules must match each of
+	// them. It is nil for other tools.
+	Paths(argsJSON string) []string
+	Execute(ctx context.Context, argsJSON string, workingDir string) (ToolResult, error)
 }
 
 // Typed is a generic adapter that handles json.Unmarshal once.
@@ -25,19 +42,51 @@ type Typed[A any] struct {
 	ToolName        string
 	ToolDescription string
 	ToolSchema      json.RawMessage
-	Run             func(args A, workingDir string) (ToolResult, error)
+	ToolInfo        Info
+	ToolFormat      func(args A) string
+	ToolChanges     func(args A, result, workingDir string) []FileChange
+	ToolPaths       func(args A) []string
+	Run             func(ctx context.Context, args A, workingDir string) (ToolResult, error)
 }
 
-func (t Typed[A]) Name() string              { return t.ToolName }
-func (t Typed[A]) Description() string        { return t.ToolDescription }
-func (t Typed[A]) Schema() json.RawMessage    { return t.ToolSchema }
+func (t Typed[A]) Name() string            { return t.ToolName }
+func (t Typed[A]) Description() string     { return t.ToolDescription }
+func (t Typed[A]) Schema() json.RawMessage { return t.ToolSchema }
+func (t Typed[A]) Info() Info              { return t.ToolInfo }
 
-func (t Typed[A]) Execute(argsJSON string, workingDir string) (ToolResult, error) {
+func (t Typed[A]) Format(argsJSON string) string {
+	var args A
+	if t.ToolFormat == nil || json.Unmarshal([]byte(argsJSON), &args) != nil {
+		return t.ToolName + ": " + argsJSON
+	}
+	return t.ToolFormat(args)
+}
+
+func (t Typed[A]) Changes(argsJSON, result, workingDir string) []FileChange {
+	var args A
+	if t.ToolChanges == nil || json.Unmarshal([]byte(argsJSON), &args) != nil {
+		return nil
+	}
+	return t.ToolChanges(args, result, workingDir)
+}
+
+func (t Typed[A]) Paths(argsJSON string) []string {
+	var args A
+	if t.ToolPaths == nil {
+		return nil
+	}
+	if json.Unmarshal([]byte(argsJSON), &args) != nil {
+		return []string{}
+	}
+	return t.ToolPaths(args)
+}
+
+func (t Typed[A]) Execute(ctx context.Context, argsJSON string, workingDir string) (ToolResult, error) {
 	var args A
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 		return ToolResult{}, fmt.Errorf("invalid arguments: %w", err)
 	}
-	return t.Run(args, workingDir)
+	return t.Run(ctx, args, workingDir)
 }
 
 // ToLLMTool converts a ToolImpl to the wire format used by the LLM client.
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkFunc is called by walkTree for every entry it visits. depth is 1 for
+// the entries directly inside the root. Returning filepath.SkipDir for a
+// directory skips its contents.
+type walkFunc func(path string, d fs.DirEntry, depth int) error
+
+// treeDir is a directory being walked, with the .gitignore rules that apply
+// to its entries.
+type treeDir struct {
+	path   string
+	rel    string // slash-separated path the rules are matched against; "" for the top
+	ignore *gitIgnore
+}
+
+// rootDir returns root prepared for walking. When root is inside workingDir,
+// paths are matched relative to workingDir so that the .gitignore files above
+// root apply too.
+func rootDir(root, workingDir string) treeDir {
+	d := treeDir{path: root}
+	if r, err := filepath.Rel(workingDir, root); err == nil && r != "." && !strings.HasPrefix(r, "..") {
+		d.rel = filepath.ToSlash(r)
+		dir, dirRel := workingDir, ""
+		for _, part := range strings.Split(d.rel, "/") {
+			d.ignore = d.ignore.withFile(dir, dirRel)
+			dir = filepath.Join(dir, part)
+			dirRel = strings.TrimPrefix(dirRel+"/"+part, "/")
+		}
+	}
+	return d
+}
+
+// entries returns the entries of d in lexical order, without .git, sensitive
+// files and what the .gitignore files exclude, and the rules that apply
+// inside d's subdirectories.
+func (d treeDir) entries() ([]fs.DirEntry, *gitIgnore) {
+	ignore := d.ignore.withFile(d.path, d.rel)
+	all, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, ignore
+	}
+	entries := all[:0]
+	for _, e := range all {
+		name := e.Name()
+		if name == ".git" || ignore.ignored(d.childRel(name), e.IsDir()) {
+			continue
+		}
+		if _, blocked := sensitive(filepath.Join(d.path, name)); blocked {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, ignore
+}
+
+// child returns the subdirectory name of d.
+func (d treeDir) child(name string, ignore *gitIgnore) treeDir {
+	return treeDir{path: filepath.Join(d.path, name), rel: d.childRel(name), ignore: ignore}
+}
+
+func (d treeDir) childRel(name string) string {
+	if d.rel == "" {
+		return name
+	}
+	return d.rel + "/" + name
+}
+
+// walkTree visits the entries below root in lexical order, skipping .git,
+// sensitive files and whatever the .gitignore files from workingDir down to
+// root and below it exclude. Directory symlinks are not followed.
+func walkTree(ctx context.Context, root, workingDir string, fn walkFunc) error {
+	err := walkDir(ctx, rootDir(root, workingDir), 1, fn)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkDir(ctx context.Context, dir treeDir, depth int, fn walkFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries, ignore := dir.entries()
+	for _, e := range entries {
+		err := fn(filepath.Join(dir.path, e.Name()), e, depth)
+		if err == filepath.SkipDir && e.IsDir() {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if err := walkDir(ctx, dir.child(e.Name(), ignore), depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
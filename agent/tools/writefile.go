@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -40,18 +41,28 @@ func init() {
 			},
 			"required": ["file_path", "content"]
 		}`),
-		Run: executeWriteFile,
+		ToolInfo: Info{
+			Category:    CategoryEdit,
+			Icon:        config.WriteIcon,
+			Destructive: true,
+			Permission:  PermissionAsk,
+			RuleArg:     "file_path",
+			RuleKind:    RulePath,
+		},
+		ToolFormat:  formatWriteFile,
+		ToolChanges: writeFileChanges,
+		Run:         executeWriteFile,
 	})
 }
 
-func executeWriteFile(args WriteFileArgs, workingDir string) (ToolResult, error) {
+func executeWriteFile(ctx context.Context, args WriteFileArgs, workingDir string) (ToolResult, error) {
 	if args.FilePath == "" {
 		return ToolResult{}, NewToolError(ErrMissingField, "file_path is required")
 	}
 
-	path := args.FilePath
-	if !filepath.IsAbs(path) {
-		path = filepath.Join(workingDir, path)
+	path, err := writablePath(args.FilePath, workingDir)
+	if err != nil {
+		return ToolResult{}, err
 	}
 
 	oldContent := ""
@@ -78,7 +89,7 @@ func executeWriteFile(args WriteFileArgs, workingDir string) (ToolResult, error)
 	}
 
 	if lsp.DefaultManager != nil {
-		if diags, diagErr := lsp.DefaultManager.CheckFile(path, args.Content); diagErr == nil {
+		if diags, diagErr := lsp.DefaultManager.CheckFile(ctx, path, args.Content); diagErr == nil {
 			if feedback := lsp.FormatDiagnostics(path, diags); feedback != "" {
 				result.LSPFeedback = "LSP Feedback: " + feedback
 			}
@@ -92,3 +103,25 @@ func executeWriteFile(args WriteFileArgs, workingDir string) (ToolResult, error)
 
 	return ToolResult{Output: string(resultJSON)}, nil
 }
+
+func formatWriteFile(args WriteFileArgs) string {
+	return "Write: " + args.FilePath
+}
+
+// writeFileChanges reports the whole file. The result carries the content
+// the file had before; for a preview it is read from disk.
+func writeFileChanges(args WriteFileArgs, result, workingDir string) []FileChange {
+	if args.FilePath == "" {
+		return nil
+	}
+	change := FileChange{FilePath: args.FilePath, NewText: args.Content, StartLine: 1}
+	var r WriteFileResult
+	if result != "" && json.Unmarshal([]byte(result), &r) == nil {
+		change.OldText = r.OldContent
+	} else if result == "" {
+		if data, err := os.ReadFile(resolvePath(args.FilePath, workingDir)); err == nil {
+			change.OldText = string(data)
+		}
+	}
+	return []FileChange{change}
+}
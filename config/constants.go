@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // Configuration constants for the application
 const (
 	// LLM Configuration
@@ -14,6 +16,8 @@ const (
 	MinBoxWidth     = 30 // Minimum width for UI boxes
 	BoxPadding      = 4  // Padding for UI boxes (2 sides)
 
+	StreamRenderInterval = 150 * time.Millisecond // Minimum time between redraws of a streaming reply
+
 	// Tool Icons
 	ToolIcon   = "🔧 "
 	EditIcon   = "✏️ "
@@ -22,12 +26,28 @@ const (
 	ListIcon   = "📁 "
 	BashIcon   = "💻 "
 	SearchIcon = "🔍 "
+	GlobIcon   = "🗂️ "
+	PatchIcon  = "🩹 "
 
 	// API Configuration
-	APIURL             = "https://api.z.ai/api/paas/v4/chat/completions"
-	ModelName          = "glm-4.5-air"
+	DefaultProvider  = "zai" // Provider used when LLM_PROVIDER is not set
+	ZAIBaseURL       = "https://api.z.ai/api/paas/v4"
+	ModelName        = "glm-4.5-air"
 	MaxContextTokens = 128000
 
+	LocalBaseURL = "http://localhost:11434/v1" // Ollama's OpenAI-compatible endpoint
+	LocalModel   = "qwen2.5-coder:7b"
+
+	AnthropicBaseURL   = "https://api.anthropic.com"
+	AnthropicModel     = "claude-sonnet-4-5"
+	AnthropicMaxTokens = 8192 // max_tokens sent with every Messages API request
+
+	// Retry Configuration
+	RetryMaxAttempts = 5                // Total attempts per LLM request, including the first
+	RetryBaseDelay   = 1 * time.Second  // Backoff before the first retry, doubled on each attempt
+	RetryMaxDelay    = 30 * time.Second // Cap on the exponential backoff delay
+	RetryMaxWait     = 2 * time.Minute  // Cap on a server-requested Retry-After delay
+
 	// File Permissions
 	DirPermissions  = 0o755 // Directory permissions
 	FilePermissions = 0o644 // File permissions
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SettingsFile is the name of the settings file inside a settings directory.
+const SettingsFile = "settings.json"
+
+// Settings holds user-tunable configuration loaded from settings files.
+// Constants in this package remain the defaults.
+type Settings struct {
+	// Prices maps a model name to its price, used to show session cost.
+	Prices map[string]Price `json:"prices,omitempty"`
+	// ContextWindows maps a model name to its context window in tokens.
+	// Models not listed use MaxContextTokens.
+	ContextWindows map[string]int `json:"context_windows,omitempty"`
+	// Compaction controls automatic compaction of long conversations.
+	Compaction CompactionSettings `json:"compaction"`
+	// Workspace controls which paths the file tools may access.
+	Workspace WorkspaceSettings `json:"workspace"`
+	// Sandbox controls confinement of bash commands.
+	Sandbox SandboxSettings `json:"sandbox"`
+	// Bash controls how long bash commands may run.
+	Bash BashSettings `json:"bash"`
+	// ToolOutput limits how much command output the model sees.
+	ToolOutput OutputSettings `json:"tool_output"`
+}
+
+// OutputSettings caps the output of bash and beads calls. Longer output keeps
+// its beginning and end, and the full text is saved to a file the model can
+// read. Zero means no limit.
+type OutputSettings struct {
+	MaxLines int `json:"max_lines"`
+	MaxBytes int `json:"max_bytes"`
+}
+
+// BashSettings bounds the run time of foreground bash commands. Commands
+// started with run_in_background are not limited.
+type BashSettings struct {
+	TimeoutSeconds    int `json:"timeout_seconds"`     // for calls that do not pass a timeout
+	MaxTimeoutSeconds int `json:"max_timeout_seconds"` // upper bound on the timeout a call may ask for
+}
+
+// SandboxSettings is the sandbox profile for bash commands on Linux. When
+// enabled, commands see the filesystem read-only except for the working tree
+// and Writable, get a scratch HOME, and have no network unless Network is set.
+type SandboxSettings struct {
+	Enabled  bool     `json:"enabled"`
+	Backend  string   `json:"backend,omitempty"`  // "bwrap" or "unshare"; empty picks the first available
+	Network  bool     `json:"network"`            // allow network access
+	Writable []string `json:"writable,omitempty"` // extra writable paths; relative paths are relative to the working directory
+}
+
+// WorkspaceSettings confines the file tools. Paths are resolved through
+// symlinks before they are checked.
+type WorkspaceSettings struct {
+	// ExtraRoots are directories outside the working directory the file tools
+	// may also access. Relative paths are relative to the working directory.
+	ExtraRoots []string `json:"extra_roots,omitempty"`
+	// Sensitive lists files the file tools never access, even inside the
+	// workspace. Entries starting with / or ~/ block that path and everything
+	// below it; other entries are matched against file names, e.g. ".env.*".
+	Sensitive []string `json:"sensitive"`
+}
+
+// CompactionSettings controls when and how history is compacted automatically.
+type CompactionSettings struct {
+	Auto      bool    `json:"auto"`       // compact automatically before a request would overflow
+	Threshold float64 `json:"threshold"`  // fraction of the context window that triggers compaction
+	KeepTurns int     `json:"keep_turns"` // most recent user turns kept verbatim
+}
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// Cost returns the USD cost of the given token counts.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.InputPerMTok + float64(completionTokens)*p.OutputPerMTok) / 1e6
+}
+
+// Current is the active configuration. It starts as Defaults() and is
+// replaced by Load at startup.
+var Current = Defaults()
+
+// Defaults returns the built-in settings.
+func Defaults() *Settings {
+	return &Settings{
+		Prices: map[string]Price{
+			"glm-4.5-air":       {InputPerMTok: 0.20, OutputPerMTok: 1.10},
+			"glm-4.5":           {InputPerMTok: 0.60, OutputPerMTok: 2.20},
+			"claude-sonnet-4-5": {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+			"claude-opus-4-1":   {InputPerMTok: 15.00, OutputPerMTok: 75.00},
+			"claude-haiku-4-5":  {InputPerMTok: 1.00, OutputPerMTok: 5.00},
+		},
+		ContextWindows: map[string]int{
+			"glm-4.5-air":       128000,
+			"glm-4.5":           128000,
+			"claude-sonnet-4-5": 200000,
+			"claude-opus-4-1":   200000,
+			"claude-haiku-4-5":  200000,
+		},
+		Compaction: CompactionSettings{
+			Auto:      true,
+			Threshold: 0.8,
+			KeepTurns: 2,
+		},
+		Workspace: WorkspaceSettings{
+			Sensitive: []string{".env", ".env.*", "*.pem", "id_rsa*", "id_ed25519*", "~/.ssh", "~/.aws", "~/.gnupg"},
+		},
+		Bash: BashSettings{
+			TimeoutSeconds:    120,
+			MaxTimeoutSeconds: 600,
+		},
+		ToolOutput: OutputSettings{
+			MaxLines: 400,
+			MaxBytes: 30000,
+		},
+	}
+}
+
+// ContextWindow returns the context window of model in tokens.
+func (s *Settings) ContextWindow(model string) int {
+	if n, ok := s.ContextWindows[model]; ok && n > 0 {
+		return n
+	}
+	return MaxContextTokens
+}
+
+// PriceFor returns the price of model, if one is configured.
+func (s *Settings) PriceFor(model string) (Price, bool) {
+	p, ok := s.Prices[model]
+	return p, ok
+}
+
+// UserDir returns the per-user settings directory (e.g. ~/.config/go-tui).
+func UserDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-tui")
+}
+
+// ProjectDir returns the per-project settings directory inside workingDir.
+func ProjectDir(workingDir string) string {
+	return filepath.Join(workingDir, ".go-tui")
+}
+
+// Load reads the user settings file and then the project settings file on
+// top of the defaults, and makes the result Current. Fields present in a later
+// file override earlier ones; map entries are merged. Missing files are fine.
+func Load(workingDir string) error {
+	s := Defaults()
+	for _, dir := range []string{UserDir(), ProjectDir(workingDir)} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, SettingsFile)
+		b, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading settings: %w", err)
+		}
+		if err := json.Unmarshal(b, s); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	Current = s
+	return nil
+}
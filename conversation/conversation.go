@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
 	"go-tui/config"
@@ -15,6 +16,37 @@ type Data struct {
 	ID           string          `json:"id"`
 	UIMessages   json.RawMessage `json:"ui_messages"`
 	AgentHistory json.RawMessage `json:"agent_history"`
+	Usage        *UsageTotals    `json:"usage,omitempty"`
+	Compactions  []Compaction    `json:"compactions,omitempty"`
+}
+
+// Compaction records a point where older agent history was replaced by a
+// summary. UI messages are kept, so MessageIndex marks where in them the
+// compaction happened.
+type Compaction struct {
+	Time               time.Time `json:"time"`
+	Auto               bool      `json:"auto"`
+	MessageIndex       int       `json:"message_index"`
+	SummarizedMessages int       `json:"summarized_messages"`
+	KeptMessages       int       `json:"kept_messages"`
+	TokensBefore       int       `json:"tokens_before"`
+}
+
+// UsageTotals accumulates token usage and cost over every LLM request made
+// in a conversation.
+type UsageTotals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Add records one request's usage and cost.
+func (u *UsageTotals) Add(promptTokens, completionTokens int, cost float64) {
+	u.Requests++
+	u.PromptTokens += promptTokens
+	u.CompletionTokens += completionTokens
+	u.CostUSD += cost
 }
 
 func New() *Data {
@@ -23,6 +55,7 @@ func New() *Data {
 		ID:           id.String(),
 		UIMessages:   []byte("[]"),
 		AgentHistory: []byte("[]"),
+		Usage:        &UsageTotals{},
 	}
 }
 
@@ -35,6 +68,9 @@ func Load(path string) (*Data, error) {
 	if err := json.Unmarshal(b, &d); err != nil {
 		return nil, fmt.Errorf("parsing conversation file: %w", err)
 	}
+	if d.Usage == nil {
+		d.Usage = &UsageTotals{}
+	}
 	return &d, nil
 }
 
@@ -0,0 +1,383 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go-tui/config"
+)
+
+const anthropicVersion = "2023-06-01"
+
+func init() {
+	RegisterProvider("anthropic", func() (Provider, error) {
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY not set in .env file")
+		}
+		baseURL := os.Getenv("ANTHROPIC_BASE_URL")
+		if baseURL == "" {
+			baseURL = config.AnthropicBaseURL
+		}
+		return NewAnthropicProvider(baseURL, apiKey, modelFromEnv(config.AnthropicModel)), nil
+	})
+}
+
+// AnthropicProvider talks to the Anthropic Messages API. Content blocks are
+// mapped onto the shared Message/ToolCall/Delta types so the rest of the
+// application stays format-agnostic.
+type AnthropicProvider struct {
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+// NewAnthropicProvider creates a provider for the Messages API rooted at baseURL.
+func NewAnthropicProvider(baseURL, apiKey, model string) *AnthropicProvider {
+	return &AnthropicProvider{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		apiKey:    apiKey,
+		model:     model,
+		maxTokens: config.AnthropicMaxTokens,
+		client:    http.DefaultClient,
+	}
+}
+
+func (p *AnthropicProvider) Name() string  { return "anthropic" }
+func (p *AnthropicProvider) Model() string { return p.model }
+
+// Wire types for the Messages API.
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+type anthropicStreamEvent struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *anthropicResponse `json:"message,omitempty"`
+	ContentBlock *anthropicBlock    `json:"content_block,omitempty"`
+	Delta        *anthropicDelta    `json:"delta,omitempty"`
+	Usage        *anthropicUsage    `json:"usage,omitempty"`
+	Error        *anthropicError    `json:"error,omitempty"`
+}
+
+type anthropicDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// toAnthropicRequest converts OpenAI-style messages into the Messages API
+// shape: system messages are hoisted into the system field, tool results
+// become tool_result blocks in a user turn, and consecutive messages with the
+// same role are merged because the API requires alternating turns.
+func toAnthropicRequest(model string, maxTokens int, messages []Message, tools []Tool, stream bool) anthropicRequest {
+	req := anthropicRequest{
+		Model:     model,
+		MaxTokens: maxTokens,
+		Stream:    stream,
+	}
+
+	var system []string
+	for _, msg := range messages {
+		var role string
+		var blocks []anthropicBlock
+
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+			continue
+		case "tool":
+			role = "user"
+			blocks = append(blocks, anthropicBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			})
+		case "assistant":
+			role = "assistant"
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
+			}
+		default:
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, blocks...)
+			continue
+		}
+		req.Messages = append(req.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	for _, t := range tools {
+		req.Tools = append(req.Tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		})
+	}
+	return req
+}
+
+func (u anthropicUsage) toUsage() *Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return &Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+}
+
+func (p *AnthropicProvider) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	return httpReq, nil
+}
+
+func (p *AnthropicProvider) send(httpReq *http.Request) (*http.Response, error) {
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
+
+func (p *AnthropicProvider) post(ctx context.Context, req anthropicRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	httpReq, err := p.newRequest(ctx, "POST", "/v1/messages", body)
+	if err != nil {
+		return nil, err
+	}
+	return p.send(httpReq)
+}
+
+func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message, tools []Tool) (*LLMResult, error) {
+	resp, err := p.post(ctx, toAnthropicRequest(p.model, p.maxTokens, messages, tools, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var msgResp anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&msgResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	delta := &Delta{Role: "assistant"}
+	for _, block := range msgResp.Content {
+		switch block.Type {
+		case "text":
+			delta.Content += block.Text
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			delta.ToolCalls = append(delta.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: block.Name, Arguments: args},
+			})
+		}
+	}
+
+	return &LLMResult{Delta: delta, Usage: msgResp.Usage.toUsage()}, nil
+}
+
+func (p *AnthropicProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, onContent func(string, bool)) (*LLMResult, error) {
+	resp, err := p.post(ctx, toAnthropicRequest(p.model, p.maxTokens, messages, tools, true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	full := &Delta{Role: "assistant"}
+	var usage anthropicUsage
+	var streamErr error
+	finished := false
+	// Content block index -> position in full.ToolCalls
+	toolIndex := map[int]int{}
+
+	err = readSSE(resp.Body, func(ev sseEvent) bool {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+			return true
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage = event.Message.Usage
+			}
+
+		case "content_block_start":
+			if event.ContentBlock == nil {
+				return true
+			}
+			switch event.ContentBlock.Type {
+			case "tool_use":
+				toolIndex[event.Index] = len(full.ToolCalls)
+				full.ToolCalls = append(full.ToolCalls, ToolCall{
+					ID:       event.ContentBlock.ID,
+					Type:     "function",
+					Function: ToolCallFunction{Name: event.ContentBlock.Name},
+				})
+			case "text":
+				if event.ContentBlock.Text != "" {
+					full.Content += event.ContentBlock.Text
+					onContent(event.ContentBlock.Text, false)
+				}
+			}
+
+		case "content_block_delta":
+			if event.Delta == nil {
+				return true
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				full.Content += event.Delta.Text
+				onContent(event.Delta.Text, false)
+			case "input_json_delta":
+				if i, ok := toolIndex[event.Index]; ok {
+					full.ToolCalls[i].Function.Arguments += event.Delta.PartialJSON
+				}
+			}
+
+		case "content_block_stop":
+			if i, ok := toolIndex[event.Index]; ok && full.ToolCalls[i].Function.Arguments == "" {
+				full.ToolCalls[i].Function.Arguments = "{}"
+			}
+
+		case "message_delta":
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+
+		case "message_stop":
+			finished = true
+			return false
+
+		case "error":
+			streamErr = &StreamEventError{Type: "error"}
+			if event.Error != nil {
+				streamErr = &StreamEventError{Type: event.Error.Type, Message: event.Error.Message}
+			}
+			return false
+		}
+		return true
+	})
+	if err == nil {
+		err = streamErr
+	}
+	if err == nil && !finished {
+		err = errStreamTruncated
+	}
+
+	return &LLMResult{Delta: full, Usage: usage.toUsage()}, err
+}
+
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	httpReq, err := p.newRequest(ctx, "GET", "/v1/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.send(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list ModelList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// replayServer serves a recorded Messages API stream from testdata and keeps
+// the last request it received.
+type replayServer struct {
+	*httptest.Server
+	header http.Header
+	body   anthropicRequest
+}
+
+func newReplayServer(t *testing.T, recording string) *replayServer {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &replayServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		s.header = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &s.body); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// deltas records what ChatStream passes to onContent.
+type deltas struct {
+	content, reasoning []string
+}
+
+func (d *deltas) onContent(s string, thinking bool) {
+	if thinking {
+		d.reasoning = append(d.reasoning, s)
+	} else {
+		d.content = append(d.content, s)
+	}
+}
+
+func TestAnthropicStreamText(t *testing.T) {
+	srv := newReplayServer(t, "anthropic_text.sse")
+	p := NewAnthropicProvider(srv.URL+"/", "test-key", "test-model")
+
+	var d deltas
+	result, err := p.ChatStream(context.Background(), []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Say hello"},
+	}, nil, d.onContent)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	if got := srv.header.Get("x-api-key"); got != "test-key" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	if got := srv.header.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("anthropic-version = %q", got)
+	}
+	if !srv.body.Stream || srv.body.Model != "test-model" || srv.body.System != "Be brief." {
+		t.Errorf("request = %+v, want a streaming request for test-model with the system prompt hoisted", srv.body)
+	}
+
+	if want := []string{"Hello", ", wörld!"}; !reflect.DeepEqual(d.content, want) {
+		t.Errorf("content deltas = %q, want %q", d.content, want)
+	}
+	if len(d.reasoning) != 0 {
+		t.Errorf("reasoning deltas = %q, want none", d.reasoning)
+	}
+	if result.Delta.Content != "Hello, wörld!" || result.Delta.Role != "assistant" {
+		t.Errorf("delta = %+v", result.Delta)
+	}
+	// Cached input counts towards the prompt; output comes from message_delta.
+	want := Usage{PromptTokens: 125, CompletionTokens: 15, TotalTokens: 140}
+	if *result.Usage != want {
+		t.Errorf("usage = %+v, want %+v", *result.Usage, want)
+	}
+}
+
+func TestAnthropicStreamToolUse(t *testing.T) {
+	srv := newReplayServer(t, "anthropic_tool_use.sse")
+	p := NewAnthropicProvider(srv.URL, "test-key", "test-model")
+
+	tools := []Tool{{Type: "function", Function: ToolFunction{
+		Name:        "read_file",
+		Description: "Read a file",
+		Parameters:  json.RawMessage(`{"type":"object"}`),
+	}}}
+	var d deltas
+	result, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "What's in the README?"}}, tools, d.onContent)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	if len(srv.body.Tools) != 1 || srv.body.Tools[0].Name != "read_file" || string(srv.body.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Errorf("request tools = %+v", srv.body.Tools)
+	}
+
+	if len(d.reasoning) != 0 {
+		t.Errorf("reasoning deltas = %q, want none", d.reasoning)
+	}
+	if result.Delta.Content != "Let me look." {
+		t.Errorf("content = %q", result.Delta.Content)
+	}
+
+	// The arguments arrive in pieces that split keys and values; a tool
+	// with no input gets an empty object.
+	want := []ToolCall{
+		{ID: "toolu_01T1x1fJ34qAmk2tNTrN7Up6", Type: "function", Function: ToolCallFunction{Name: "read_file", Arguments: `{"file_path": "README.md"}`}},
+		{ID: "toolu_01D7FLrfh4GYq7yT1ULFeyMV", Type: "function", Function: ToolCallFunction{Name: "list_files", Arguments: "{}"}},
+	}
+	if !reflect.DeepEqual(result.Delta.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", result.Delta.ToolCalls, want)
+	}
+	for _, tc := range result.Delta.ToolCalls {
+		if !json.Valid([]byte(tc.Function.Arguments)) {
+			t.Errorf("%s arguments are not valid JSON: %q", tc.Function.Name, tc.Function.Arguments)
+		}
+	}
+	if result.Usage.CompletionTokens != 89 {
+		t.Errorf("completion tokens = %d, want 89", result.Usage.CompletionTokens)
+	}
+}
+
+func TestAnthropicStreamErrorEvent(t *testing.T) {
+	srv := newReplayServer(t, "anthropic_overloaded.sse")
+	p := NewAnthropicProvider(srv.URL, "test-key", "test-model")
+
+	var d deltas
+	result, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, d.onContent)
+	var streamErr *StreamEventError
+	if !errors.As(err, &streamErr) || streamErr.Type != "overloaded_error" || streamErr.Message != "Overloaded" {
+		t.Fatalf("err = %v, want an overloaded_error StreamEventError", err)
+	}
+	if !IsRetryable(err) {
+		t.Error("overloaded_error is not retryable")
+	}
+	if result == nil || result.Delta.Content != "Partial" {
+		t.Errorf("result = %+v, want the partial content", result)
+	}
+}
+
+func TestAnthropicStreamTruncated(t *testing.T) {
+	srv := newReplayServer(t, "anthropic_truncated.sse")
+	p := NewAnthropicProvider(srv.URL, "test-key", "test-model")
+
+	result, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, func(string, bool) {})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !IsRetryable(err) {
+		t.Fatalf("err = %v, want a retryable truncation error", err)
+	}
+	if result.Delta.Content != "Cut" {
+		t.Errorf("content = %q, want %q", result.Delta.Content, "Cut")
+	}
+}
+
+func TestAnthropicAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Number of request tokens has exceeded your per-minute rate limit"}}`))
+	}))
+	defer srv.Close()
+	p := NewAnthropicProvider(srv.URL, "test-key", "test-model")
+
+	_, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, func(string, bool) {})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter.Seconds() != 7 || !strings.Contains(apiErr.Body, "rate_limit_error") {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestToAnthropicRequest(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "one"},
+		{Role: "system", Content: "two"},
+		{Role: "user", Content: "list and read"},
+		{Role: "assistant", Content: "Sure.", ToolCalls: []ToolCall{
+			{ID: "a", Function: ToolCallFunction{Name: "list_files", Arguments: `{"path":"."}`}},
+			{ID: "b", Function: ToolCallFunction{Name: "read_file", Arguments: `{"file_pa`}},
+		}},
+		{Role: "tool", ToolCallID: "a", Content: "README.md"},
+		{Role: "tool", ToolCallID: "b", Content: "invalid arguments"},
+		{Role: "user", Content: "thanks"},
+	}
+	req := toAnthropicRequest("m", 100, messages, nil, false)
+
+	if req.System != "one\n\ntwo" {
+		t.Errorf("system = %q", req.System)
+	}
+	// Both tool results and the following user message share one user turn.
+	want := []anthropicMessage{
+		{Role: "user", Content: []anthropicBlock{{Type: "text", Text: "list and read"}}},
+		{Role: "assistant", Content: []anthropicBlock{
+			{Type: "text", Text: "Sure."},
+			{Type: "tool_use", ID: "a", Name: "list_files", Input: json.RawMessage(`{"path":"."}`)},
+			{Type: "tool_use", ID: "b", Name: "read_file", Input: json.RawMessage(`{}`)},
+		}},
+		{Role: "user", Content: []anthropicBlock{
+			{Type: "tool_result", ToolUseID: "a", Content: "README.md"},
+			{Type: "tool_result", ToolUseID: "b", Content: "invalid arguments"},
+			{Type: "text", Text: "thanks"},
+		}},
+	}
+	if !reflect.DeepEqual(req.Messages, want) {
+		got, _ := json.MarshalIndent(req.Messages, "", "  ")
+		t.Errorf("messages =\n%s", got)
+	}
+}
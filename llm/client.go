@@ -1,67 +1,113 @@
 package llm
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
 
-	"github.com/joho/godotenv"
 	"go-tui/config"
 )
 
-const (
-	apiURL    = config.APIURL
-	modelName = config.ModelName
-)
+func init() {
+	RegisterProvider("zai", func() (Provider, error) {
+		apiKey := os.Getenv("ZAI_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ZAI_API_KEY not set in .env file")
+		}
+		return NewOpenAIProvider("zai", config.ZAIBaseURL, apiKey, modelFromEnv(config.ModelName)), nil
+	})
+
+	// Ollama, llama.cpp's server and similar run an OpenAI-compatible
+	// endpoint locally; the API key is optional.
+	RegisterProvider("local", func() (Provider, error) {
+		baseURL := os.Getenv("LLM_BASE_URL")
+		if baseURL == "" {
+			baseURL = config.LocalBaseURL
+		}
+		p := NewOpenAIProvider("local", baseURL, os.Getenv("LLM_API_KEY"), modelFromEnv(config.LocalModel))
+		p.InlineToolCalls = true
+		return p, nil
+	})
+}
 
-var apiKey string
+// OpenAIProvider talks to any endpoint implementing the OpenAI-style
+// chat completions API, such as Z.AI.
+type OpenAIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+
+	// InlineToolCalls enables recovering tool calls that the server emitted
+	// as JSON text in the message content instead of tool_calls deltas.
+	InlineToolCalls bool
+}
 
-func InitAPIKey() error {
-	if err := godotenv.Load(); err != nil {
-		return fmt.Errorf("error loading .env file: %w", err)
+// NewOpenAIProvider creates a provider for the chat completions API rooted at baseURL.
+func NewOpenAIProvider(name, baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  http.DefaultClient,
 	}
-	apiKey = os.Getenv("ZAI_API_KEY")
-	if apiKey == "" {
-		return fmt.Errorf("ZAI_API_KEY not set in .env file")
-	}
-	return nil
 }
 
-func CallLLM(messages []Message, tools []Tool) (*LLMResult, error) {
-	req := ChatRequest{
-		Model:    modelName,
-		Messages: messages,
-		Tools:    tools,
-		Stream:   false,
+func (p *OpenAIProvider) Name() string  { return p.name }
+func (p *OpenAIProvider) Model() string { return p.model }
+
+func (p *OpenAIProvider) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
 	}
+	return httpReq, nil
+}
 
+func (p *OpenAIProvider) post(ctx context.Context, req ChatRequest) (*http.Response, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	httpReq, err := p.newRequest(ctx, "POST", "/chat/completions", body)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, err
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := p.client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errBody bytes.Buffer
-		errBody.ReadFrom(resp.Body)
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, errBody.String())
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
 	}
+	return resp, nil
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []Tool) (*LLMResult, error) {
+	resp, err := p.post(ctx, ChatRequest{
+		Model:    p.model,
+		Messages: messages,
+		Tools:    tools,
+		Stream:   false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
 	var chatResp ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
@@ -72,60 +118,42 @@ func CallLLM(messages []Message, tools []Tool) (*LLMResult, error) {
 		return nil, fmt.Errorf("no choices in response")
 	}
 
+	delta := chatResp.Choices[0].Message
+	if delta == nil {
+		delta = &Delta{Role: "assistant"}
+	}
+	p.recoverInlineToolCalls(delta, tools)
+
 	return &LLMResult{
-		Delta: chatResp.Choices[0].Message,
+		Delta: delta,
 		Usage: chatResp.Usage,
 	}, nil
 }
 
-func CallLLMStream(messages []Message, tools []Tool, onContent func(string, bool)) (*LLMResult, error) {
-	req := ChatRequest{
-		Model:    modelName,
+func (p *OpenAIProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, onContent func(string, bool)) (*LLMResult, error) {
+	resp, err := p.post(ctx, ChatRequest{
+		Model:    p.model,
 		Messages: messages,
 		Tools:    tools,
 		Stream:   true,
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
-
-	resp, err := http.DefaultClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		var errBody bytes.Buffer
-		errBody.ReadFrom(resp.Body)
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, errBody.String())
-	}
-
 	full := &Delta{}
 	var usage *Usage
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
+	finished := false
+	err = readSSE(resp.Body, func(ev sseEvent) bool {
+		if ev.Data == "[DONE]" {
+			finished = true
+			return false
 		}
 
 		var chunk ChatResponse
-		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			continue
+		if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+			return true
 		}
 
 		if chunk.Usage != nil {
@@ -133,12 +161,16 @@ func CallLLMStream(messages []Message, tools []Tool, onContent func(string, bool
 		}
 
 		if len(chunk.Choices) == 0 {
-			continue
+			return true
+		}
+
+		if chunk.Choices[0].FinishReason != nil && *chunk.Choices[0].FinishReason != "" {
+			finished = true
 		}
 
 		delta := chunk.Choices[0].Delta
 		if delta == nil {
-			continue
+			return true
 		}
 
 		if delta.ReasoningContent != "" {
@@ -159,7 +191,50 @@ func CallLLMStream(messages []Message, tools []Tool, onContent func(string, bool
 				}
 			}
 		}
+		return true
+	})
+	if err == nil && !finished {
+		err = errStreamTruncated
+	}
+	p.recoverInlineToolCalls(full, tools)
+
+	return &LLMResult{Delta: full, Usage: usage}, err
+}
+
+func (p *OpenAIProvider) recoverInlineToolCalls(d *Delta, tools []Tool) {
+	if !p.InlineToolCalls || len(d.ToolCalls) > 0 || len(tools) == 0 {
+		return
+	}
+	if content, calls := ExtractInlineToolCalls(d.Content, tools); len(calls) > 0 {
+		d.Content = content
+		d.ToolCalls = calls
+	}
+}
+
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	httpReq, err := p.newRequest(ctx, "GET", "/models", nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return &LLMResult{Delta: full, Usage: usage}, scanner.Err()
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var list ModelList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
 }
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// APIError is a non-200 response from a provider's HTTP API.
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the server's Retry-After header,
+	// or zero if there was none.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Body)
+}
+
+// StreamEventError is an error reported inside an otherwise successful stream,
+// such as Anthropic's "overloaded_error" event.
+type StreamEventError struct {
+	Type    string
+	Message string
+}
+
+func (e *StreamEventError) Error() string {
+	return fmt.Sprintf("stream error: %s: %s", e.Type, e.Message)
+}
+
+// errStreamTruncated is returned when a stream ends without its terminator.
+var errStreamTruncated = fmt.Errorf("stream ended before completion: %w", io.ErrUnexpectedEOF)
+
+// newAPIError reads the body of a failed response into an APIError.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter understands both forms of the Retry-After header: a number
+// of seconds or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// rate limiting, server errors, overload events, dropped or refused
+// connections and timeouts. Other network errors, such as an unknown host or
+// a bad certificate, will not go away by waiting.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode == http.StatusRequestTimeout ||
+			apiErr.StatusCode >= 500
+	}
+
+	var streamErr *StreamEventError
+	if errors.As(err, &streamErr) {
+		return streamErr.Type == "overloaded_error" || streamErr.Type == "api_error"
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
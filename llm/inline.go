@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var (
+	toolCallTagRe = regexp.MustCompile(`(?s)<tool_call>\s*(.*?)\s*</tool_call>`)
+	codeFenceRe   = regexp.MustCompile("(?s)```(?:json)?\\s*\\n(.*?)\\n?```")
+)
+
+// inlineCall is the loose shape small local models use when they write a
+// tool call as text: {"name": ..., "arguments": {...}}, with "parameters"
+// as a common alias and an optional OpenAI-style "function" wrapper.
+type inlineCall struct {
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments"`
+	Parameters json.RawMessage `json:"parameters"`
+	Function   *inlineCall     `json:"function"`
+}
+
+// ExtractInlineToolCalls looks for tool calls written as JSON in content,
+// either inside <tool_call> tags, in a fenced code block, after a
+// [TOOL_CALLS] marker, or as the entire message. Only calls naming one of
+// the offered tools are accepted. It returns the content with the recognised
+// calls removed, and the calls themselves.
+func ExtractInlineToolCalls(content string, tools []Tool) (string, []ToolCall) {
+	known := make(map[string]bool, len(tools))
+	for _, t := range tools {
+		known[t.Function.Name] = true
+	}
+
+	if matches := toolCallTagRe.FindAllStringSubmatchIndex(content, -1); len(matches) > 0 {
+		return extractMatches(content, matches, known)
+	}
+	if matches := codeFenceRe.FindAllStringSubmatchIndex(content, -1); len(matches) > 0 {
+		if rest, calls := extractMatches(content, matches, known); len(calls) > 0 {
+			return rest, calls
+		}
+	}
+
+	trimmed := strings.TrimSpace(content)
+	trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "[TOOL_CALLS]"))
+	if calls := parseInlineCalls(trimmed, known); len(calls) > 0 {
+		return "", calls
+	}
+	return content, nil
+}
+
+// extractMatches parses the first submatch of every regexp match as tool
+// calls and cuts the matches that produced calls out of content.
+func extractMatches(content string, matches [][]int, known map[string]bool) (string, []ToolCall) {
+	var calls []ToolCall
+	var rest strings.Builder
+	last := 0
+	for _, m := range matches {
+		parsed := parseInlineCalls(content[m[2]:m[3]], known)
+		if len(parsed) == 0 {
+			continue
+		}
+		calls = append(calls, parsed...)
+		rest.WriteString(content[last:m[0]])
+		last = m[1]
+	}
+	rest.WriteString(content[last:])
+	return strings.TrimSpace(rest.String()), calls
+}
+
+// parseInlineCalls decodes a single call object or an array of them.
+func parseInlineCalls(text string, known map[string]bool) []ToolCall {
+	text = strings.TrimSpace(text)
+	if text == "" || (text[0] != '{' && text[0] != '[') {
+		return nil
+	}
+
+	var raw []inlineCall
+	if text[0] == '[' {
+		if json.Unmarshal([]byte(text), &raw) != nil {
+			return nil
+		}
+	} else {
+		var one inlineCall
+		if json.Unmarshal([]byte(text), &one) != nil {
+			return nil
+		}
+		raw = []inlineCall{one}
+	}
+
+	var calls []ToolCall
+	for _, c := range raw {
+		if c.Function != nil {
+			c = *c.Function
+		}
+		if !known[c.Name] {
+			return nil
+		}
+		args := c.Arguments
+		if len(args) == 0 {
+			args = c.Parameters
+		}
+		calls = append(calls, ToolCall{
+			ID:       newInlineCallID(),
+			Type:     "function",
+			Function: ToolCallFunction{Name: c.Name, Arguments: inlineArguments(args)},
+		})
+	}
+	return calls
+}
+
+// inlineArguments normalises arguments to a JSON object string. Some models
+// emit the arguments as an already-encoded JSON string.
+func inlineArguments(args json.RawMessage) string {
+	if len(args) == 0 || string(args) == "null" {
+		return "{}"
+	}
+	var s string
+	if json.Unmarshal(args, &s) == nil {
+		if json.Valid([]byte(s)) {
+			return s
+		}
+		return "{}"
+	}
+	return string(args)
+}
+
+func newInlineCallID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
@@ -1,8 +1,53 @@
 package llm
 
-func CallLLMMock(_ []Message, _ []Tool, responseText string) (*Delta, error) {
-	return &Delta{
+import "context"
+
+func init() {
+	RegisterProvider("mock", func() (Provider, error) {
+		return &MockProvider{}, nil
+	})
+}
+
+// MockProvider is an offline stand-in that never leaves the process. It
+// replies with Response, or echoes the last user message when Response is empty.
+type MockProvider struct {
+	Response string
+}
+
+func (p *MockProvider) Name() string  { return "mock" }
+func (p *MockProvider) Model() string { return "mock" }
+
+func (p *MockProvider) Chat(ctx context.Context, messages []Message, _ []Tool) (*LLMResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &LLMResult{Delta: &Delta{
 		Role:    "assistant",
-		Content: responseText,
-	}, nil
+		Content: p.reply(messages),
+	}}, nil
+}
+
+func (p *MockProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, onContent func(string, bool)) (*LLMResult, error) {
+	result, err := p.Chat(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	onContent(result.Delta.Content, false)
+	return result, nil
+}
+
+func (p *MockProvider) ListModels(_ context.Context) ([]string, error) {
+	return []string{"mock"}, nil
+}
+
+func (p *MockProvider) reply(messages []Message) string {
+	if p.Response != "" {
+		return p.Response
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return "You said: " + messages[i].Content
+		}
+	}
+	return "Hello from the mock provider."
 }
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"go-tui/config"
+)
+
+// Provider is an LLM backend the TUI can talk to. Implementations translate
+// between the shared Message/Tool types and their own wire format.
+type Provider interface {
+	// Name returns the registry name of the provider (e.g. "zai").
+	Name() string
+	// Model returns the model identifier requests are sent to.
+	Model() string
+	// Chat sends a non-streaming completion request.
+	Chat(ctx context.Context, messages []Message, tools []Tool) (*LLMResult, error)
+	// ChatStream sends a streaming completion request, calling onContent for
+	// every content delta. The bool argument is true for reasoning content.
+	// If the stream breaks off, the partial result is returned with the error.
+	ChatStream(ctx context.Context, messages []Message, tools []Tool, onContent func(string, bool)) (*LLMResult, error)
+	// ListModels returns the model identifiers available to this provider.
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ProviderFactory builds a provider from the current environment.
+type ProviderFactory func() (Provider, error)
+
+var providers = map[string]ProviderFactory{}
+
+// RegisterProvider makes a provider available to NewProvider under name.
+func RegisterProvider(name string, factory ProviderFactory) {
+	if _, exists := providers[name]; exists {
+		panic(fmt.Sprintf("duplicate provider registration: %s", name))
+	}
+	providers[name] = factory
+}
+
+// ProviderNames returns the names of all registered providers, sorted.
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider constructs the registered provider with the given name.
+func NewProvider(name string) (Provider, error) {
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(ProviderNames(), ", "))
+	}
+	return factory()
+}
+
+// NewProviderFromEnv loads the .env file, if there is one, and constructs the
+// provider named by LLM_PROVIDER, falling back to config.DefaultProvider.
+// Transient failures are retried with DefaultRetryPolicy.
+func NewProviderFromEnv() (Provider, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error loading .env file: %w", err)
+	}
+	name := os.Getenv("LLM_PROVIDER")
+	if name == "" {
+		name = config.DefaultProvider
+	}
+	p, err := NewProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	return WithRetry(p, DefaultRetryPolicy()), nil
+}
+
+// modelFromEnv returns LLM_MODEL if set, otherwise fallback.
+func modelFromEnv(fallback string) string {
+	if m := os.Getenv("LLM_MODEL"); m != "" {
+		return m
+	}
+	return fallback
+}
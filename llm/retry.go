@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"go-tui/config"
+)
+
+// RetryPolicy controls how transient provider failures are retried.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first
+	BaseDelay   time.Duration // delay before the first retry, doubled each time
+	MaxDelay    time.Duration // cap on the computed backoff delay
+	MaxWait     time.Duration // cap on a server-requested Retry-After delay
+}
+
+// DefaultRetryPolicy returns the policy configured in the config package.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: config.RetryMaxAttempts,
+		BaseDelay:   config.RetryBaseDelay,
+		MaxDelay:    config.RetryMaxDelay,
+		MaxWait:     config.RetryMaxWait,
+	}
+}
+
+// delay returns how long to wait before retrying after the given attempt.
+// A Retry-After from the server wins; otherwise exponential backoff with
+// jitter spreads retries from concurrent clients apart.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, p.MaxWait)
+	}
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// RetryEvent describes a retry that is about to happen. For a streaming
+// request the next attempt streams its answer from the start, so content the
+// failed attempt streamed must be discarded. The observer is called on the
+// goroutine that calls ChatStream, between the attempts' onContent calls.
+type RetryEvent struct {
+	Attempt     int // the attempt that is about to start (2 for the first retry)
+	MaxAttempts int
+	Delay       time.Duration
+	Err         error // the failure that triggered the retry
+}
+
+type retryObserverKey struct{}
+
+// WithRetryObserver returns a context that reports retries of requests made
+// with it to fn, so callers can show progress while a request is backing off.
+func WithRetryObserver(ctx context.Context, fn func(RetryEvent)) context.Context {
+	return context.WithValue(ctx, retryObserverKey{}, fn)
+}
+
+func notifyRetry(ctx context.Context, ev RetryEvent) {
+	if fn, ok := ctx.Value(retryObserverKey{}).(func(RetryEvent)); ok {
+		fn(ev)
+	}
+}
+
+// WithRetry wraps p so that transient failures are retried according to policy.
+func WithRetry(p Provider, policy RetryPolicy) Provider {
+	return &retryProvider{Provider: p, policy: policy}
+}
+
+type retryProvider struct {
+	Provider
+	policy RetryPolicy
+}
+
+func (r *retryProvider) do(ctx context.Context, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= r.policy.MaxAttempts || ctx.Err() != nil || !IsRetryable(err) {
+			return err
+		}
+
+		delay := r.policy.delay(attempt, err)
+		log.Printf("llm: attempt %d/%d failed, retrying in %s: %v", attempt, r.policy.MaxAttempts, delay, err)
+		notifyRetry(ctx, RetryEvent{
+			Attempt:     attempt + 1,
+			MaxAttempts: r.policy.MaxAttempts,
+			Delay:       delay,
+			Err:         err,
+		})
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *retryProvider) Chat(ctx context.Context, messages []Message, tools []Tool) (*LLMResult, error) {
+	var result *LLMResult
+	err := r.do(ctx, func() error {
+		var err error
+		result, err = r.Provider.Chat(ctx, messages, tools)
+		return err
+	})
+	return result, err
+}
+
+// ChatStream retries the whole request when a stream fails, including after
+// it has started. A new attempt generates its answer afresh, so it cannot be
+// spliced onto what the failed one streamed: callers that show streamed
+// content should discard it when they are notified of the retry (see
+// RetryEvent). The returned result always comes from the final attempt.
+func (r *retryProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, onContent func(string, bool)) (*LLMResult, error) {
+	var result *LLMResult
+	err := r.do(ctx, func() error {
+		var err error
+		result, err = r.Provider.ChatStream(ctx, messages, tools, onContent)
+		return err
+	})
+	return result, err
+}
+
+func (r *retryProvider) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	err := r.do(ctx, func() error {
+		var err error
+		models, err = r.Provider.ListModels(ctx)
+		return err
+	})
+	return models, err
+}
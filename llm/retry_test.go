@@ -0,0 +1,300 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testPolicy retries quickly so that the tests do not wait on real backoff.
+var testPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Millisecond,
+	MaxWait:     10 * time.Millisecond,
+}
+
+// fakeServer serves the chat completions endpoint with the handler for each
+// attempt in turn; attempts beyond the list reuse the last one.
+func fakeServer(t *testing.T, attempts ...http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		attempts[min(n, len(attempts))-1](w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func status(code int, header ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"error":"status %d"}`, code)
+	}
+}
+
+// stream sends deltas as OpenAI-style chunks and then ends the stream: with
+// [DONE] if done is set, otherwise by dropping the connection.
+func stream(done bool, deltas ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, d := range deltas {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", d)
+		}
+		w.(http.Flusher).Flush()
+		if !done {
+			panic(http.ErrAbortHandler)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+func newTestProvider(srv *httptest.Server) Provider {
+	return WithRetry(NewOpenAIProvider("test", srv.URL, "", "test-model"), testPolicy)
+}
+
+// streamChat runs ChatStream the way the TUI does, discarding the partial
+// content on each retry, and returns the content that was left.
+func streamChat(ctx context.Context, p Provider) (string, *LLMResult, []RetryEvent, error) {
+	var content strings.Builder
+	var events []RetryEvent
+	ctx = WithRetryObserver(ctx, func(ev RetryEvent) {
+		events = append(events, ev)
+		content.Reset()
+	})
+	result, err := p.ChatStream(ctx, []Message{{Role: "user", Content: "hi"}}, nil, func(s string, thinking bool) {
+		if !thinking {
+			content.WriteString(s)
+		}
+	})
+	return content.String(), result, events, err
+}
+
+func TestRetryRateLimitHonoursRetryAfter(t *testing.T) {
+	srv, calls := fakeServer(t, status(http.StatusTooManyRequests, "Retry-After", "1"), stream(true, "Hello"))
+
+	content, result, events, err := streamChat(context.Background(), newTestProvider(srv))
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if content != "Hello" || result.Delta.Content != "Hello" {
+		t.Errorf("content = %q, result = %q, want %q", content, result.Delta.Content, "Hello")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("server called %d times, want 2", got)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d retry events, want 1", len(events))
+	}
+	var apiErr *APIError
+	if !errors.As(events[0].Err, &apiErr) || apiErr.RetryAfter != time.Second {
+		t.Errorf("retry error = %v, want an APIError with a 1s Retry-After", events[0].Err)
+	}
+	// The server asked for a second; MaxWait caps it.
+	if events[0].Delay != testPolicy.MaxWait {
+		t.Errorf("delay = %s, want %s", events[0].Delay, testPolicy.MaxWait)
+	}
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	srv, calls := fakeServer(t, status(http.StatusServiceUnavailable), status(http.StatusBadGateway), stream(true, "ok"))
+
+	content, _, events, err := streamChat(context.Background(), newTestProvider(srv))
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if content != "ok" {
+		t.Errorf("content = %q, want %q", content, "ok")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server called %d times, want 3", got)
+	}
+	for i, ev := range events {
+		if ev.Attempt != i+2 || ev.MaxAttempts != testPolicy.MaxAttempts {
+			t.Errorf("event %d: attempt %d/%d, want %d/%d", i, ev.Attempt, ev.MaxAttempts, i+2, testPolicy.MaxAttempts)
+		}
+		if ev.Delay > testPolicy.MaxDelay {
+			t.Errorf("event %d: delay %s exceeds MaxDelay", i, ev.Delay)
+		}
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	srv, calls := fakeServer(t, status(http.StatusInternalServerError))
+
+	_, _, events, err := streamChat(context.Background(), newTestProvider(srv))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("err = %v, want a 500 APIError", err)
+	}
+	if got := calls.Load(); got != int32(testPolicy.MaxAttempts) {
+		t.Errorf("server called %d times, want %d", got, testPolicy.MaxAttempts)
+	}
+	if len(events) != testPolicy.MaxAttempts-1 {
+		t.Errorf("got %d retry events, want %d", len(events), testPolicy.MaxAttempts-1)
+	}
+}
+
+func TestRetrySkipsClientErrors(t *testing.T) {
+	srv, calls := fakeServer(t, status(http.StatusBadRequest))
+
+	_, _, events, err := streamChat(context.Background(), newTestProvider(srv))
+	if err == nil {
+		t.Fatal("ChatStream succeeded, want an error")
+	}
+	if got := calls.Load(); got != 1 || len(events) != 0 {
+		t.Errorf("server called %d times with %d retry events, want 1 and none", got, len(events))
+	}
+}
+
+func TestRetryDroppedStreamStartsOver(t *testing.T) {
+	// The second attempt answers differently, as a model may; nothing of the
+	// first one must survive.
+	srv, calls := fakeServer(t, stream(false, "Hel", "lo, wor"), stream(true, "Hi", " there"))
+
+	content, result, events, err := streamChat(context.Background(), newTestProvider(srv))
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if content != "Hi there" || result.Delta.Content != "Hi there" {
+		t.Errorf("content = %q, result = %q, want %q", content, result.Delta.Content, "Hi there")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("server called %d times, want 2", got)
+	}
+	if len(events) != 1 || !IsRetryable(events[0].Err) {
+		t.Errorf("retry events = %v, want one for the dropped stream", events)
+	}
+}
+
+func TestRetryTruncatedStream(t *testing.T) {
+	// The connection closes cleanly but the stream never finishes.
+	truncated := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"part\"}}]}\n\n")
+	}
+	srv, _ := fakeServer(t, truncated, stream(true, "whole"))
+
+	content, _, events, err := streamChat(context.Background(), newTestProvider(srv))
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if content != "whole" {
+		t.Errorf("content = %q, want %q", content, "whole")
+	}
+	if len(events) != 1 || !errors.Is(events[0].Err, io.ErrUnexpectedEOF) {
+		t.Errorf("retry events = %v, want one for the truncated stream", events)
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	srv, calls := fakeServer(t, status(http.StatusServiceUnavailable))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = WithRetryObserver(ctx, func(RetryEvent) { cancel() })
+	_, err := newTestProvider(srv).Chat(ctx, []Message{{Role: "user", Content: "hi"}}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times, want 1", got)
+	}
+}
+
+func TestRetryRefusedConnection(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close() // nothing listens on its address any more
+
+	_, _, events, err := streamChat(context.Background(), newTestProvider(srv))
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("err = %v, want a refused connection", err)
+	}
+	if len(events) != testPolicy.MaxAttempts-1 {
+		t.Errorf("got %d retry events, want %d", len(events), testPolicy.MaxAttempts-1)
+	}
+}
+
+func TestRetrySkipsCertificateErrors(t *testing.T) {
+	// The client does not trust the test server's certificate.
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, _, events, err := streamChat(context.Background(), newTestProvider(srv))
+	if err == nil {
+		t.Fatal("ChatStream succeeded, want a certificate error")
+	}
+	if len(events) != 0 {
+		t.Errorf("got %d retry events for %v, want none", len(events), err)
+	}
+}
+
+func TestIsRetryableNetworkErrors(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Post", URL: "https://api.example.com/v1", Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refused", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{"reset", wrap(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}), true},
+		{"dial timeout", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}), true},
+		{"deadline", wrap(&net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}), true},
+		{"unknown host", wrap(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "api.exmaple.com", IsNotFound: true}}), false},
+		{"bad certificate", wrap(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), false},
+		{"unsupported scheme", wrap(errors.New(`unsupported protocol scheme "htps"`)), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"0", 0},
+		{"-1", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, want up to a minute", future, got)
+	}
+}
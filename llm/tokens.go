@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// Per-message and per-request framing overhead, as documented for OpenAI chat models.
+const (
+	tokensPerMessage = 4
+	tokensPerRequest = 3
+)
+
+// defaultEncoding is used for models tiktoken does not know, such as Claude,
+// GLM or local models, whose tokenizers are not public or not BPE-compatible.
+const defaultEncoding = "cl100k_base"
+
+func init() {
+	// The vocabularies are embedded, so counting never goes to the network.
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = map[string]*tiktoken.Tiktoken{}
+)
+
+// encodingFor returns the tokenizer of model: the one tiktoken names for
+// OpenAI models, o200k_base for the newer ones it does not list yet and
+// defaultEncoding for everything else. It returns nil if the vocabulary
+// cannot be loaded.
+func encodingFor(model string) *tiktoken.Tiktoken {
+	name := encodingName(model)
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if enc, ok := encodings[name]; ok {
+		return enc
+	}
+	enc, _ := tiktoken.GetEncoding(name)
+	encodings[name] = enc
+	return enc
+}
+
+func encodingName(model string) string {
+	// Routers such as OpenRouter prefix the model with its vendor.
+	model = model[strings.LastIndexByte(model, '/')+1:]
+	if name, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return name
+	}
+	for prefix, name := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if strings.HasPrefix(model, prefix) {
+			return name
+		}
+	}
+	for _, prefix := range []string{"gpt-5", "gpt-4.1", "gpt-4o", "o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return "o200k_base"
+		}
+	}
+	return defaultEncoding
+}
+
+// EstimateTokens returns how many tokens text encodes to for model. The count
+// is exact for OpenAI models. Other models are counted with cl100k_base, so
+// their count is only an estimate; callers that have real usage numbers
+// should prefer them.
+func EstimateTokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	enc := encodingFor(model)
+	if enc == nil {
+		return (len(text) + 3) / 4
+	}
+	return len(enc.EncodeOrdinary(text))
+}
+
+// EstimatePromptTokens estimates the prompt size of a request to model: every
+// message (content, tool calls and framing) plus the tool schemas. Providers
+// serialize tool calls and schemas in their own way, so even for OpenAI
+// models this is an estimate.
+func EstimatePromptTokens(model string, messages []Message, tools []Tool) int {
+	n := tokensPerRequest
+	for _, msg := range messages {
+		n += tokensPerMessage + EstimateTokens(model, msg.Role) + EstimateTokens(model, msg.Content)
+		for _, tc := range msg.ToolCalls {
+			n += EstimateTokens(model, tc.Function.Name) + EstimateTokens(model, tc.Function.Arguments)
+		}
+	}
+	for _, t := range tools {
+		n += EstimateTokens(model, t.Function.Name) + EstimateTokens(model, t.Function.Description) +
+			EstimateTokens(model, string(t.Function.Parameters))
+	}
+	return n
+}
@@ -66,3 +66,11 @@ type LLMResult struct {
 	Delta *Delta
 	Usage *Usage
 }
+
+type ModelList struct {
+	Data []ModelInfo `json:"data"`
+}
+
+type ModelInfo struct {
+	ID string `json:"id"`
+}
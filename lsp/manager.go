@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -85,8 +86,9 @@ func NewManager(workingDir string) *Manager {
 }
 
 // CheckFile looks up the appropriate server by file extension, starts it lazily,
-// and returns diagnostics. Returns nil, nil if no server handles this extension.
-func (m *Manager) CheckFile(filePath string, content string) ([]Diagnostic, error) {
+// and returns diagnostics. Returns nil, nil if no server handles this extension,
+// and ctx.Err() if the check was cancelled.
+func (m *Manager) CheckFile(ctx context.Context, filePath string, content string) ([]Diagnostic, error) {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	if ext == "" {
 		return nil, nil
@@ -116,7 +118,11 @@ func (m *Manager) CheckFile(filePath string, content string) ([]Diagnostic, erro
 	m.mu.Unlock()
 
 	log.Printf("lsp: checking %s with %s", filepath.Base(filePath), cfg.Name)
-	diags, err := srv.CheckFile(filePath, content)
+	diags, err := srv.CheckFile(ctx, filePath, content)
+	if ctx.Err() != nil {
+		log.Printf("lsp: %s check of %s cancelled", cfg.Name, filepath.Base(filePath))
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		log.Printf("lsp: %s CheckFile error: %v", cfg.Name, err)
 		// Server may have crashed — remove it so next call restarts
@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -164,7 +165,8 @@ func (s *Server) Stop() {
 }
 
 // CheckFile sends didOpen, waits for diagnostics, then sends didClose.
-func (s *Server) CheckFile(filePath string, content string) ([]Diagnostic, error) {
+// Waiting stops early if ctx is cancelled.
+func (s *Server) CheckFile(ctx context.Context, filePath string, content string) ([]Diagnostic, error) {
 	s.mu.Lock()
 	if s.stopped {
 		s.mu.Unlock()
@@ -199,6 +201,7 @@ func (s *Server) CheckFile(filePath string, content string) ([]Diagnostic, error
 	case diags = <-s.diagCh:
 	case <-time.After(5 * time.Second):
 		// Timeout — return empty
+	case <-ctx.Done():
 	}
 
 	// Send didClose
@@ -206,7 +209,7 @@ func (s *Server) CheckFile(filePath string, content string) ([]Diagnostic, error
 		TextDocument: TextDocumentIdentifier{URI: uri},
 	})
 
-	return diags, nil
+	return diags, ctx.Err()
 }
 
 // sendRequest sends a JSON-RPC request and waits for the response.
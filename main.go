@@ -11,6 +11,7 @@ import (
 	"go-tui/config"
 	"go-tui/conversation"
 	"go-tui/llm"
+	"go-tui/permission"
 	"go-tui/tui"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -25,7 +26,8 @@ func main() {
 		resumeID = flag.Arg(0)
 	}
 
-	if err := llm.InitAPIKey(); err != nil {
+	provider, err := llm.NewProviderFromEnv()
+	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -36,6 +38,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := config.Load(workingDir); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := permission.Load(workingDir); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	logDir := filepath.Join(workingDir, "log")
 	if err := os.MkdirAll(logDir, config.DirPermissions); err != nil {
 		fmt.Printf("Error creating log dir: %v\n", err)
@@ -49,7 +60,7 @@ func main() {
 	defer logFile.Close()
 	log.SetOutput(logFile)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	log.Println("starting go-tui")
+	log.Printf("starting go-tui (provider=%s model=%s)", provider.Name(), provider.Model())
 
 	convDir := conversation.Dir(workingDir)
 	var conv *conversation.Data
@@ -84,7 +95,7 @@ func main() {
 		log.Printf("new conversation: %s", conv.ID)
 	}
 
-	m := tui.New(workingDir, conv)
+	m := tui.New(workingDir, conv, provider)
 	p := tea.NewProgram(&m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error: %v\n", err)
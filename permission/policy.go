@@ -0,0 +1,366 @@
+// Package permission decides which tool calls may run without asking the
+// user, based on rules read from permission files.
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go-tui/agent/tools"
+	"go-tui/config"
+)
+
+// PolicyFile is the name of the permission file inside a settings directory.
+const PolicyFile = "permissions.json"
+
+// file is the on-disk form of a permission file.
+type file struct {
+	Allow []string `json:"allow,omitempty"`
+	Ask   []string `json:"ask,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Policy is the combined set of user and project rules.
+type Policy struct {
+	allow, ask, deny []Rule
+	session          []Rule // allow rules added from the prompt for this session only
+	projectFile      string // where rules added from the prompt are saved
+}
+
+// Current is the active policy. It is empty until Load is called.
+var Current = &Policy{}
+
+// Load reads the user and project permission files and makes the result
+// Current. Rules from both files apply; missing files are fine.
+func Load(workingDir string) error {
+	p := &Policy{projectFile: filepath.Join(config.ProjectDir(workingDir), PolicyFile)}
+	for _, dir := range []string{config.UserDir(), config.ProjectDir(workingDir)} {
+		if dir == "" {
+			continue
+		}
+		f, err := readFile(filepath.Join(dir, PolicyFile))
+		if err != nil {
+			return err
+		}
+		for _, list := range []struct {
+			raw   []string
+			rules *[]Rule
+		}{{f.Allow, &p.allow}, {f.Ask, &p.ask}, {f.Deny, &p.deny}} {
+			for _, s := range list.raw {
+				r, err := ParseRule(s)
+				if err != nil {
+					return fmt.Errorf("%s: %w", filepath.Join(dir, PolicyFile), err)
+				}
+				*list.rules = append(*list.rules, r)
+			}
+		}
+	}
+	Current = p
+	return nil
+}
+
+func readFile(path string) (*file, error) {
+	var f file
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading permissions: %w", err)
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Decision is the policy's verdict on a call.
+type Decision struct {
+	Permission tools.Permission // "" when no rule decides
+	Risks      []string         // why the call needs confirmation whatever the rules say
+}
+
+// Decide evaluates the rules for a call. Deny rules win over ask rules, which
+// win over allow rules. A shell command is split into its simple commands and
+// every one of them must be allowed, so allowing "ls" does not allow
+// "ls; rm -rf ~".
+func (p *Policy) Decide(name, argsJSON, workingDir string) Decision {
+	subjects, kind := Subjects(name, argsJSON, workingDir)
+	if kind != tools.RuleShell {
+		return Decision{Permission: p.decide(name, subjects, kind == tools.RulePath)}
+	}
+	subject := subjects[0]
+
+	a, err := AnalyzeShell(subject, workingDir, tools.ShellDir(workingDir))
+	if err != nil {
+		return Decision{
+			Permission: p.decide(name, []string{subject}, false),
+			Risks:      []string{"the command could not be parsed: " + err.Error()},
+		}
+	}
+	d := Decision{Permission: p.decide(name, a.Commands, false), Risks: a.Risks}
+	if matchAny(p.deny, name, subject, false) {
+		// A deny rule may also name the command line as written
+		d.Permission = tools.PermissionDeny
+	}
+	return d
+}
+
+// decide returns the verdict for a call whose rule argument consists of
+// subjects: deny or ask if any subject matches such a rule, allow if all of
+// them match allow rules, and "" otherwise. Deny and ask rules written for
+// one file-editing tool hold for all of them, so "deny write_file(secrets/**)"
+// also stops edits and patches there.
+func (p *Policy) decide(name string, subjects []string, isPath bool) tools.Permission {
+	for _, c := range []struct {
+		rules    []Rule
+		decision tools.Permission
+	}{
+		{p.deny, tools.PermissionDeny},
+		{p.ask, tools.PermissionAsk},
+	} {
+		for _, subject := range subjects {
+			if matchAny(c.rules, name, subject, isPath) || matchEditors(c.rules, name, subject, isPath) {
+				return c.decision
+			}
+		}
+	}
+	if len(subjects) == 0 {
+		return ""
+	}
+	for _, subject := range subjects {
+		if !matchAny(p.allow, name, subject, isPath) && !matchAny(p.session, name, subject, isPath) {
+			return ""
+		}
+	}
+	return tools.PermissionAllow
+}
+
+func matchAny(rules []Rule, name, subject string, isPath bool) bool {
+	for _, r := range rules {
+		if r.Matches(name, subject, isPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEditors reports whether a rule for another file-editing tool matches
+// subject, when name is one too.
+func matchEditors(rules []Rule, name, subject string, isPath bool) bool {
+	if tools.InfoFor(name).Category != tools.CategoryEdit {
+		return false
+	}
+	for _, r := range rules {
+		if r.Tool != name && tools.InfoFor(r.Tool).Category == tools.CategoryEdit &&
+			(Rule{Tool: name, Pattern: r.Pattern}).Matches(name, subject, isPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAllow adds allow rules and saves them to the project permission file.
+func (p *Policy) AddAllow(rules ...Rule) error {
+	for _, r := range rules {
+		if !slices.Contains(p.allow, r) {
+			p.allow = append(p.allow, r)
+		}
+	}
+	if p.projectFile == "" {
+		return nil
+	}
+
+	f, err := readFile(p.projectFile)
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		if !slices.Contains(f.Allow, r.String()) {
+			f.Allow = append(f.Allow, r.String())
+		}
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.projectFile), config.DirPermissions); err != nil {
+		return fmt.Errorf("creating settings dir: %w", err)
+	}
+	return os.WriteFile(p.projectFile, append(b, '\n'), config.FilePermissions)
+}
+
+// AllowForSession adds allow rules that hold until exit and are not saved.
+func (p *Policy) AllowForSession(rules ...Rule) {
+	for _, r := range rules {
+		if !slices.Contains(p.session, r) {
+			p.session = append(p.session, r)
+		}
+	}
+}
+
+// Subjects returns the arguments of a call that rules match against, and how
+// they are matched: the paths of a tool that touches several files, or else
+// the single subject.
+func Subjects(name, argsJSON, workingDir string) ([]string, tools.RuleKind) {
+	t, ok := tools.Lookup(name)
+	if !ok {
+		return []string{""}, tools.RuleText
+	}
+	paths := t.Paths(argsJSON)
+	if paths == nil {
+		subject, kind := Subject(name, argsJSON, workingDir)
+		return []string{subject}, kind
+	}
+	if len(paths) == 0 {
+		return []string{""}, tools.RulePath
+	}
+	subjects := make([]string, len(paths))
+	for i, path := range paths {
+		subjects[i] = pathSubject(path, workingDir)
+	}
+	return subjects, tools.RulePath
+}
+
+// Subject returns the argument of a call that rules match against, and how
+// it is matched. Paths have their symlinks resolved and are made relative to
+// workingDir when inside it.
+func Subject(name, argsJSON, workingDir string) (string, tools.RuleKind) {
+	info := tools.InfoFor(name)
+	if info.RuleArg == "" {
+		return "", info.RuleKind
+	}
+	var args map[string]any
+	if json.Unmarshal([]byte(argsJSON), &args) != nil {
+		return "", info.RuleKind
+	}
+	subject, _ := args[info.RuleArg].(string)
+	if info.RuleKind != tools.RulePath {
+		return strings.TrimSpace(subject), info.RuleKind
+	}
+
+	return pathSubject(subject, workingDir), tools.RulePath
+}
+
+// pathSubject returns path as rules see it: with symlinks resolved, so that
+// a link cannot lead around a rule, and relative to workingDir when inside it.
+func pathSubject(path, workingDir string) string {
+	if path == "" {
+		path = "."
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	path = tools.RealPath(filepath.Clean(path))
+	if rel, err := filepath.Rel(tools.RealPath(workingDir), path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	return path
+}
+
+// SuggestRules returns rules the permission prompt can offer to save for a
+// call: exact allows this call, prefix allows calls like it. For a command
+// that is its program and subcommand ("bash(git status *)"), for a path its
+// directory ("edit_file(src/**)"). A shell command line gets rules for each
+// of its commands that is not yet allowed. No prefix rules are offered for
+// destructive or risky commands, or for paths at the top of the working
+// directory, whose prefix would be all of it.
+func (p *Policy) SuggestRules(name, argsJSON, workingDir string) (exact, prefix []Rule) {
+	subjects, kind := Subjects(name, argsJSON, workingDir)
+	widen := true
+	if kind == tools.RuleShell {
+		a, err := AnalyzeShell(subjects[0], workingDir, tools.ShellDir(workingDir))
+		if err != nil {
+			return nil, nil
+		}
+		subjects = nil
+		for _, c := range a.Commands {
+			if !slices.Contains(subjects, c) && !matchAny(p.allow, name, c, false) && !matchAny(p.session, name, c, false) {
+				subjects = append(subjects, c)
+			}
+		}
+		widen = len(a.Risks) == 0
+	}
+
+	for _, s := range subjects {
+		if s == "" || strings.ContainsAny(s, "*?") {
+			return nil, nil
+		}
+		exact = append(exact, Rule{Tool: name, Pattern: s})
+		if !widen {
+			continue
+		}
+		r, ok := prefixRule(name, s, kind == tools.RulePath)
+		if !ok {
+			widen, prefix = false, nil
+			continue
+		}
+		if !slices.Contains(prefix, r) {
+			prefix = append(prefix, r)
+		}
+	}
+	if slices.Equal(exact, prefix) {
+		prefix = nil
+	}
+	return exact, prefix
+}
+
+// prefixRule returns the rule allowing calls like subject, or false if none
+// should be offered.
+func prefixRule(name, subject string, isPath bool) (Rule, bool) {
+	if isPath {
+		dir := filepath.Dir(subject)
+		if dir == "." || dir == "/" {
+			return Rule{}, false
+		}
+		return Rule{Tool: name, Pattern: dir + "/**"}, true
+	}
+
+	fields := strings.Fields(subject)
+	if len(fields) == 0 {
+		return Rule{}, false
+	}
+	prefix := fields[0]
+	if len(fields) > 1 && isSubcommand(fields[0], fields[1]) {
+		prefix += " " + fields[1]
+	}
+	if slices.Contains(destructive, filepath.Base(prefix)) || slices.Contains(destructive, filepath.Base(fields[0])) {
+		return Rule{}, false
+	}
+	// "ls *" also matches a bare "ls", but not "lsof".
+	return Rule{Tool: name, Pattern: prefix + " *"}, true
+}
+
+// destructive lists commands, and program and subcommand pairs, that delete
+// or overwrite data. Allowing them by prefix would allow far more than the
+// call the user saw.
+var destructive = []string{
+	"rm", "rmdir", "unlink", "shred", "truncate", "dd", "mkfs", "mv", "cp", "ln", "chmod", "chown", "chgrp",
+	"kill", "pkill", "killall", "git clean", "git reset", "git push", "git checkout", "git restore", "git rebase",
+}
+
+// subcommandTools are programs whose second word selects what they do.
+var subcommandTools = []string{
+	"apt", "brew", "bd", "bun", "cargo", "deno", "docker", "dotnet", "gh", "git", "go", "gradle",
+	"kubectl", "make", "mvn", "npm", "npx", "pip", "pnpm", "systemctl", "terraform", "yarn",
+}
+
+// isSubcommand reports whether word, following program, is a subcommand
+// ("test" in "go test") rather than a flag or an operand.
+func isSubcommand(program, word string) bool {
+	if !slices.Contains(subcommandTools, filepath.Base(program)) {
+		return false
+	}
+	for _, r := range word {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return false
+		}
+	}
+	return word != "" && word[0] != '-'
+}
@@ -0,0 +1,47 @@
+package permission
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go-tui/agent/tools"
+)
+
+func bashArgs(command string) string {
+	b, _ := json.Marshal(map[string]string{"command": command})
+	return string(b)
+}
+
+// Allowing a shell command for the session allows the commands it was made
+// of, not the tool: a different command line is still asked about.
+func TestAllowForSessionOnlyAllowsAnalyzedCommands(t *testing.T) {
+	dir := t.TempDir()
+	p := &Policy{}
+	exact, _ := p.SuggestRules("bash", bashArgs("ls src"), dir)
+	if len(exact) != 1 || exact[0] != (Rule{Tool: "bash", Pattern: "ls src"}) {
+		t.Fatalf("exact rules = %v, want bash(ls src)", exact)
+	}
+	p.AllowForSession(exact...)
+
+	tests := []struct {
+		command string
+		want    tools.Permission
+	}{
+		{"ls src", tools.PermissionAllow},
+		{"ls src && ls src", tools.PermissionAllow},
+		{"ls src; rm -rf src", ""},
+		{"rm -rf src", ""},
+		{"ls", ""},
+	}
+	for _, tt := range tests {
+		if got := p.Decide("bash", bashArgs(tt.command), dir).Permission; got != tt.want {
+			t.Errorf("Decide(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	// Rules allowed for the session are not suggested again.
+	exact, _ = p.SuggestRules("bash", bashArgs("ls src; rm -rf src"), dir)
+	if len(exact) != 1 || exact[0].Pattern != "rm -rf src" {
+		t.Errorf("exact rules = %v, want only bash(rm -rf src)", exact)
+	}
+}
@@ -0,0 +1,105 @@
+package permission
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Rule matches calls to one tool, optionally narrowed by a pattern on the
+// tool's rule argument: "bash" matches every bash call, "bash(git status *)"
+// only "git status" with any arguments, "edit_file(src/**)" only edits below
+// src/.
+type Rule struct {
+	Tool    string
+	Pattern string // empty matches any call
+}
+
+// ParseRule parses "tool" or "tool(pattern)".
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		if s == "" || strings.ContainsAny(s, " )") {
+			return Rule{}, fmt.Errorf("invalid rule %q", s)
+		}
+		return Rule{Tool: s}, nil
+	}
+	if !strings.HasSuffix(s, ")") || open == 0 {
+		return Rule{}, fmt.Errorf("invalid rule %q: want tool(pattern)", s)
+	}
+	return Rule{Tool: strings.TrimSpace(s[:open]), Pattern: s[open+1 : len(s)-1]}, nil
+}
+
+func (r Rule) String() string {
+	if r.Pattern == "" {
+		return r.Tool
+	}
+	return r.Tool + "(" + r.Pattern + ")"
+}
+
+// Matches reports whether the rule covers a call to tool whose rule argument
+// is subject. For commands, * matches any text, and a trailing " *" also
+// matches nothing, so "ls *" covers "ls" and "ls -la" but not "lsof". For
+// paths, * matches within
+// one path segment and ** across segments; a pattern without a slash also
+// matches the file's base name anywhere, like .gitignore.
+func (r Rule) Matches(tool, subject string, isPath bool) bool {
+	if r.Tool != tool {
+		return false
+	}
+	if r.Pattern == "" {
+		return true
+	}
+	if !isPath {
+		return globRegexp(strings.TrimSpace(r.Pattern), false).MatchString(strings.TrimSpace(subject))
+	}
+
+	pattern := expandHome(r.Pattern)
+	if globRegexp(pattern, true).MatchString(subject) {
+		return true
+	}
+	return !strings.Contains(pattern, "/") && globRegexp(pattern, true).MatchString(filepath.Base(subject))
+}
+
+// globRegexp compiles a glob pattern to an anchored regexp.
+func globRegexp(pattern string, isPath bool) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	end := "$"
+	if rest, ok := strings.CutSuffix(pattern, " *"); ok && !isPath {
+		pattern, end = rest, "(?: .*)?$"
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case isPath && strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && isPath && strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*' && isPath:
+			sb.WriteString("[^/]*")
+		case c == '*':
+			sb.WriteString(".*")
+		case c == '?' && isPath:
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString(end)
+	return regexp.MustCompile(sb.String())
+}
+
+func expandHome(pattern string) string {
+	if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return pattern
+}
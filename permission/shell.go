@@ -0,0 +1,509 @@
+package permission
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go-tui/config"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ShellAnalysis is what a bash command line does, as far as can be told
+// without running it.
+type ShellAnalysis struct {
+	// Commands holds every simple command, including those inside
+	// pipelines, lists, subshells and command substitutions, e.g.
+	// ["ls -la", "rm -rf ~"] for "ls -la; rm -rf ~". A command run by
+	// another, as by env, sudo, xargs or find -exec, follows it on its own:
+	// ["sudo rm -rf /tmp/x", "rm -rf /tmp/x"].
+	Commands []string
+	// Risks lists reasons the command always needs explicit confirmation.
+	Risks []string
+}
+
+var (
+	// shells run whatever they read, so a rule cannot vouch for their input.
+	shells = []string{"sh", "bash", "zsh", "dash", "ksh", "fish", "csh", "tcsh"}
+	// elevators run their arguments with other privileges.
+	elevators = []string{"sudo", "doas", "su", "pkexec"}
+	// writersAll write to every path operand; writersLast to the last one.
+	writersAll  = []string{"rm", "rmdir", "mkdir", "touch", "tee", "truncate", "chmod", "chown", "chgrp", "shred", "unlink"}
+	writersLast = []string{"cp", "mv", "ln", "install", "rsync"}
+	// harmlessTargets may always be written to.
+	harmlessTargets = []string{"/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty"}
+)
+
+// wrapper describes a command that runs the command in its operands, such as
+// "env FOO=1 make" or "xargs rm".
+type wrapper struct {
+	argOpts  []string // options that take the next word as their value unless joined to it
+	operands int      // operands before the command, e.g. the duration of timeout
+}
+
+var wrappers = map[string]wrapper{
+	"builtin": {},
+	"command": {},
+	"doas":    {argOpts: []string{"-u", "-C"}},
+	"env":     {argOpts: []string{"-u", "--unset", "-C", "--chdir", "-S", "--split-string"}},
+	"exec":    {argOpts: []string{"-a"}},
+	"ionice":  {argOpts: []string{"-c", "--class", "-n", "--classdata"}},
+	"nice":    {argOpts: []string{"-n", "--adjustment"}},
+	"nohup":   {},
+	"stdbuf":  {argOpts: []string{"-i", "--input", "-o", "--output", "-e", "--error"}},
+	"sudo": {argOpts: []string{"-u", "--user", "-g", "--group", "-C", "--close-from", "-D", "--chdir",
+		"-h", "--host", "-p", "--prompt", "-r", "--role", "-t", "--type", "-U", "--other-user", "-T", "--command-timeout"}},
+	"taskset": {argOpts: []string{}, operands: 1},
+	"time":    {argOpts: []string{"-o", "--output", "-f", "--format"}},
+	"timeout": {argOpts: []string{"-s", "--signal", "-k", "--kill-after"}, operands: 1},
+	"xargs": {argOpts: []string{"-I", "-L", "--max-lines", "-n", "--max-args", "-P", "--max-procs",
+		"-s", "--max-chars", "-d", "--delimiter", "-E", "-a", "--arg-file"}},
+}
+
+// inputWord stands for the words xargs reads from its input.
+const inputWord = "<input>"
+
+// AnalyzeShell parses command as bash and reports its simple commands and
+// anything that makes it risky: elevated privileges, nested shells, and
+// writes outside workingDir or to its settings directory. Commands run by
+// wrappers such as env, nice, timeout, sudo, xargs and find -exec are
+// analyzed as well, and so are the directories given to git, make and tar
+// with -C. cwd is the directory the command starts in.
+func AnalyzeShell(command, workingDir, cwd string) (*ShellAnalysis, error) {
+	f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ShellAnalysis{}
+	s := shellWalker{analysis: a, workingDir: workingDir, cwd: cwd}
+	syntax.Walk(f, s.visit)
+	return a, nil
+}
+
+type shellWalker struct {
+	analysis   *ShellAnalysis
+	workingDir string
+	cwd        string // "" once a cd to an unknown directory was seen
+}
+
+func (s *shellWalker) risk(format string, args ...any) {
+	r := fmt.Sprintf(format, args...)
+	if !slices.Contains(s.analysis.Risks, r) {
+		s.analysis.Risks = append(s.analysis.Risks, r)
+	}
+}
+
+func (s *shellWalker) visit(node syntax.Node) bool {
+	switch n := node.(type) {
+	case *syntax.Stmt:
+		for _, r := range n.Redirs {
+			s.redirect(r)
+		}
+	case *syntax.BinaryCmd:
+		if n.Op == syntax.Pipe || n.Op == syntax.PipeAll {
+			if call, ok := n.Y.Cmd.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+				if name, ok := wordLiteral(call.Args[0]); ok && slices.Contains(shells, filepath.Base(name)) {
+					s.risk("pipes into %s", filepath.Base(name))
+				}
+			}
+		}
+	case *syntax.CallExpr:
+		s.call(n)
+	}
+	return true
+}
+
+func (s *shellWalker) call(call *syntax.CallExpr) {
+	if len(call.Args) == 0 {
+		return // only assignments
+	}
+	words := make([]string, len(call.Args))
+	literal := make([]bool, len(call.Args))
+	for i, w := range call.Args {
+		words[i], literal[i] = wordLiteral(w)
+		if !literal[i] {
+			words[i] = printWord(w)
+		}
+	}
+	s.command(words, literal)
+}
+
+// command analyzes a simple command given as its words, with whether each of
+// them is literal.
+func (s *shellWalker) command(words []string, literal []bool) {
+	s.analysis.Commands = append(s.analysis.Commands, strings.Join(words, " "))
+
+	if !literal[0] {
+		s.risk("runs a command whose name is only known when it runs: %s", words[0])
+		return
+	}
+	name := filepath.Base(words[0])
+	operands := func(from int) []int {
+		var idx []int
+		for i := from; i < len(words); i++ {
+			if !strings.HasPrefix(words[i], "-") || !literal[i] {
+				idx = append(idx, i)
+			}
+		}
+		return idx
+	}
+
+	switch {
+	case slices.Contains(elevators, name):
+		s.risk("runs with elevated privileges (%s)", name)
+	case name == "eval":
+		s.risk("evaluates a string as shell code (eval)")
+	case slices.Contains(shells, name) && slices.Contains(words[1:], "-c"):
+		s.risk("runs a nested shell (%s -c)", name)
+	case name == "cd" || name == "pushd":
+		ops := operands(1)
+		if len(ops) == 0 {
+			s.cwd = ""
+		} else if literal[ops[0]] && s.cwd != "" {
+			s.cwd = resolveShellPath(words[ops[0]], s.cwd)
+		} else {
+			s.cwd = ""
+		}
+	case slices.Contains(writersAll, name):
+		for _, i := range operands(1) {
+			s.write(words[i], literal[i])
+		}
+	case slices.Contains(writersLast, name):
+		if ops := operands(1); len(ops) > 1 {
+			i := ops[len(ops)-1]
+			s.write(words[i], literal[i])
+		}
+	case name == "dd":
+		for i, w := range words[1:] {
+			if target, ok := strings.CutPrefix(w, "of="); ok {
+				s.write(target, literal[i+1])
+			}
+		}
+	case name == "find":
+		s.find(words, literal)
+	case name == "git" || name == "make" || name == "tar":
+		s.directoryOptions(name, words, literal)
+	}
+	if w, ok := wrappers[name]; ok {
+		s.wrapped(name, w, words, literal)
+	}
+}
+
+// wrapped analyzes the command a wrapper runs.
+func (s *shellWalker) wrapped(name string, w wrapper, words []string, literal []bool) {
+	i := 1
+	dir, dirLiteral := "", true
+	replace := ""
+	for ; i < len(words) && literal[i] && strings.HasPrefix(words[i], "-") && words[i] != "-"; i++ {
+		if words[i] == "--" {
+			i++
+			break
+		}
+		opt, value, joined := words[i], "", false
+		if strings.HasPrefix(opt, "--") {
+			opt, value, joined = strings.Cut(opt, "=")
+		} else if len(opt) > 2 && slices.Contains(w.argOpts, opt[:2]) {
+			opt, value, joined = opt[:2], opt[2:], true
+		}
+		valueLiteral := true
+		if !joined && slices.Contains(w.argOpts, opt) && i+1 < len(words) {
+			i++
+			value, valueLiteral = words[i], literal[i]
+		}
+
+		switch {
+		case name == "env" && (opt == "-S" || opt == "--split-string"):
+			s.risk("runs a command given as a string (env -S)")
+			return
+		case name == "command" && (opt == "-v" || opt == "-V"):
+			return // only looks the command up
+		case name == "env" && (opt == "-C" || opt == "--chdir"), name == "sudo" && (opt == "-D" || opt == "--chdir"):
+			dir, dirLiteral = value, valueLiteral
+		case name == "time" && (opt == "-o" || opt == "--output"):
+			s.write(value, valueLiteral)
+		case name == "xargs" && (opt == "-I" || opt == "--replace"):
+			replace = cmp.Or(value, "{}")
+		case name == "xargs" && strings.HasPrefix(opt, "-i"):
+			replace = cmp.Or(opt[2:], "{}")
+		}
+	}
+	if name == "env" {
+		for i < len(words) && literal[i] && isAssignment(words[i]) {
+			i++
+		}
+	}
+	i += w.operands
+	if i >= len(words) {
+		return
+	}
+	inner, innerLiteral := slices.Clone(words[i:]), slices.Clone(literal[i:])
+	if name == "xargs" {
+		inner, innerLiteral = withInput(inner, innerLiteral, replace)
+	}
+
+	if dir != "" {
+		s.directory(dir, dirLiteral)
+		cwd := s.cwd
+		switch {
+		case !dirLiteral:
+			s.cwd = ""
+		case s.cwd != "" || filepath.IsAbs(dir):
+			s.cwd = resolveShellPath(dir, s.cwd)
+		}
+		defer func() { s.cwd = cwd }()
+	}
+	s.command(inner, innerLiteral)
+}
+
+// withInput returns the command xargs runs with the words it reads from its
+// input in place: where replace occurs, or else at the end.
+func withInput(words []string, literal []bool, replace string) ([]string, []bool) {
+	if replace == "" {
+		return append(words, inputWord), append(literal, false)
+	}
+	for i, w := range words {
+		if strings.Contains(w, replace) {
+			words[i], literal[i] = strings.ReplaceAll(w, replace, inputWord), false
+		}
+	}
+	return words, literal
+}
+
+// isAssignment reports whether word is a NAME=value environment assignment.
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// find analyzes what find does to the paths it walks: -delete removes them,
+// -exec and -ok run a command on them, and -fprint and the like write a file.
+// The paths a command gets in place of {} are taken to be the start paths,
+// which hold them.
+func (s *shellWalker) find(words []string, literal []bool) {
+	i := 1
+	for i < len(words) && literal[i] && (slices.Contains([]string{"-H", "-L", "-P", "-D"}, words[i]) || strings.HasPrefix(words[i], "-O")) {
+		if words[i] == "-D" {
+			i++
+		}
+		i++
+	}
+	var starts []int
+	for ; i < len(words); i++ {
+		if literal[i] && (strings.HasPrefix(words[i], "-") || words[i] == "(" || words[i] == "!") {
+			break
+		}
+		starts = append(starts, i)
+	}
+	start := func(j int) (string, bool) {
+		if len(starts) == 0 {
+			return ".", true
+		}
+		return words[starts[j]], literal[starts[j]]
+	}
+	n := max(len(starts), 1)
+
+	for ; i < len(words); i++ {
+		if !literal[i] {
+			continue
+		}
+		switch words[i] {
+		case "-delete":
+			for j := range n {
+				s.write(start(j))
+			}
+		case "-fprint", "-fprint0", "-fprintf", "-fls":
+			if i+1 < len(words) {
+				i++
+				s.write(words[i], literal[i])
+			}
+		case "-exec", "-execdir", "-ok", "-okdir":
+			end := i + 1
+			for end < len(words) && !(literal[end] && (words[end] == ";" || words[end] == "+")) {
+				end++
+			}
+			if end > i+1 {
+				for j := range n {
+					path, pathLiteral := start(j)
+					inner, innerLiteral := slices.Clone(words[i+1:end]), slices.Clone(literal[i+1:end])
+					for k, w := range inner {
+						if strings.Contains(w, "{}") {
+							inner[k] = strings.ReplaceAll(w, "{}", path)
+							innerLiteral[k] = innerLiteral[k] && pathLiteral
+						}
+					}
+					s.command(inner, innerLiteral)
+				}
+			}
+			i = end
+		}
+	}
+}
+
+// directoryOptions checks the directories git, make and tar are told to work
+// in: -C and the like for all three, --git-dir and --work-tree for git. For
+// git only the options before the subcommand count. It also flags git -c,
+// as configuration such as core.pager or an alias can run any command.
+func (s *shellWalker) directoryOptions(name string, words []string, literal []bool) {
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		if !strings.HasPrefix(w, "-") {
+			if name == "git" {
+				return
+			}
+			continue
+		}
+		// A word with an expansion, such as -C$DIR, is still an option.
+		opt, value, joined := strings.Cut(w, "=")
+		if !joined && name != "git" && len(w) > 2 && strings.HasPrefix(w, "-C") {
+			opt, value, joined = "-C", w[2:], true
+		}
+		switch {
+		case name == "git" && opt == "-c":
+			s.risk("sets git configuration, which can run commands (git -c)")
+			i++
+		case opt == "-C" || opt == "--directory" && name != "git" || name == "git" && (opt == "--git-dir" || opt == "--work-tree"):
+			if joined {
+				s.directory(value, literal[i])
+			} else if i+1 < len(words) {
+				i++
+				s.directory(words[i], literal[i])
+			}
+		}
+	}
+}
+
+// directory records a risk if dir, which a command works in, is outside the
+// working directory or cannot be determined.
+func (s *shellWalker) directory(dir string, literal bool) {
+	switch {
+	case !literal:
+		s.risk("works in a directory only known when it runs: %s", dir)
+	case s.cwd == "" && !filepath.IsAbs(dir) && !strings.HasPrefix(dir, "~"):
+		s.risk("works in %s after changing to an unknown directory", dir)
+	default:
+		if resolved := resolveShellPath(dir, s.cwd); !within(s.workingDir, resolved) {
+			s.risk("works in a directory outside the working directory: %s", resolved)
+		}
+	}
+}
+
+func (s *shellWalker) redirect(r *syntax.Redirect) {
+	switch r.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll, syntax.RdrInOut, syntax.DplOut:
+	default:
+		return
+	}
+	target, ok := wordLiteral(r.Word)
+	if r.Op == syntax.DplOut && ok && (target == "-" || strings.Trim(target, "0123456789") == "") {
+		return // duplicates a file descriptor, e.g. 2>&1
+	}
+	if !ok {
+		target = printWord(r.Word)
+	}
+	s.write(target, ok)
+}
+
+// write records a risk if path, written by the command, is outside the
+// working directory or cannot be determined.
+func (s *shellWalker) write(path string, literal bool) {
+	if !literal {
+		s.risk("writes to a path only known when it runs: %s", path)
+		return
+	}
+	if slices.Contains(harmlessTargets, path) {
+		return
+	}
+	if s.cwd == "" && !filepath.IsAbs(path) && !strings.HasPrefix(path, "~") {
+		s.risk("writes to %s after changing to an unknown directory", path)
+		return
+	}
+	resolved := resolveShellPath(path, s.cwd)
+	if !within(s.workingDir, resolved) {
+		s.risk("writes outside the working directory: %s", resolved)
+	} else if within(config.ProjectDir(s.workingDir), resolved) {
+		s.risk("writes to the settings directory: %s", resolved)
+	}
+}
+
+// resolveShellPath makes a literal shell path absolute, expanding ~.
+func resolveShellPath(path, cwd string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	return filepath.Clean(path)
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// wordLiteral returns the value of a word made only of literal text and
+// quoted literal text, and false for words with expansions.
+func wordLiteral(w *syntax.Word) (string, bool) {
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(unescape(p.Value))
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				return "", false
+			}
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			for _, dp := range p.Parts {
+				lit, ok := dp.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// unescape removes the backslashes of an unquoted literal.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func printWord(w *syntax.Word) string {
+	var sb strings.Builder
+	if err := syntax.NewPrinter().Print(&sb, w); err != nil {
+		return "?"
+	}
+	return sb.String()
+}
@@ -0,0 +1,138 @@
+// Package sandbox runs shell commands confined to the working tree. On Linux
+// it uses bubblewrap when installed and falls back to unshare; elsewhere the
+// sandbox is unavailable.
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go-tui/config"
+)
+
+// Backend names.
+const (
+	Bubblewrap = "bwrap"
+	Unshare    = "unshare"
+)
+
+// profile is the resolved sandbox configuration for one command.
+type profile struct {
+	workingDir string
+	writable   []string // absolute; the working tree comes first
+	network    bool
+	scratch    string // holds the scratch HOME and staging mounts
+	home       string // the user's real home, hidden from the command
+}
+
+// Enabled reports whether the settings ask for sandboxed commands.
+func Enabled() bool {
+	return config.Current.Sandbox.Enabled
+}
+
+// Backend returns the backend commands will use: the configured one if it is
+// installed, otherwise the first available. It returns "" if none is.
+func Backend() string {
+	candidates := backends()
+	if want := config.Current.Sandbox.Backend; want != "" {
+		candidates = []string{want}
+	}
+	for _, b := range candidates {
+		if _, err := exec.LookPath(b); err == nil {
+			return b
+		}
+	}
+	return ""
+}
+
+// Describe says how a command would run, for the permission prompt.
+func Describe() string {
+	if !Enabled() {
+		return "Runs unsandboxed"
+	}
+	b := Backend()
+	if b == "" {
+		return "Sandbox enabled but unavailable (needs Linux with bwrap or unshare): the command will not run"
+	}
+	net := "no network"
+	if config.Current.Sandbox.Network {
+		net = "network allowed"
+	}
+	return fmt.Sprintf("Runs sandboxed (%s): writes limited to the working tree, %s", b, net)
+}
+
+// Command returns a command running script with bash in workingDir,
+// sandboxed when enabled. It fails if the sandbox is enabled but unavailable
+// rather than running the script unconfined.
+func Command(script, workingDir string) (*exec.Cmd, error) {
+	if !Enabled() {
+		cmd := exec.Command("bash", "-c", script)
+		cmd.Dir = workingDir
+		return cmd, nil
+	}
+
+	backend := Backend()
+	if backend == "" {
+		return nil, fmt.Errorf("sandbox enabled but no backend is available")
+	}
+	scratch, err := scratchDir()
+	if err != nil {
+		return nil, fmt.Errorf("creating sandbox home: %w", err)
+	}
+	home, _ := os.UserHomeDir()
+	p := profile{
+		workingDir: workingDir,
+		writable:   []string{workingDir},
+		network:    config.Current.Sandbox.Network,
+		scratch:    scratch,
+		home:       home,
+	}
+	for _, w := range config.Current.Sandbox.Writable {
+		if strings.HasPrefix(w, "~/") && home != "" {
+			w = filepath.Join(home, w[2:])
+		} else if !filepath.IsAbs(w) {
+			w = filepath.Join(workingDir, w)
+		}
+		if _, err := os.Stat(w); err == nil {
+			p.writable = append(p.writable, filepath.Clean(w))
+		}
+	}
+
+	args, err := wrap(backend, p, script)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = workingDir
+	return cmd, nil
+}
+
+var (
+	scratchOnce sync.Once
+	scratchPath string
+	scratchErr  error
+)
+
+// scratchDir returns the directory holding the sandbox's scratch HOME. It is
+// shared by all commands of a session, so caches survive between commands,
+// and removed by Cleanup.
+func scratchDir() (string, error) {
+	scratchOnce.Do(func() {
+		scratchPath, scratchErr = os.MkdirTemp("", "go-tui-sandbox-")
+		if scratchErr == nil {
+			scratchErr = os.Mkdir(filepath.Join(scratchPath, "home"), 0o700)
+		}
+	})
+	return scratchPath, scratchErr
+}
+
+// Cleanup removes the scratch HOME.
+func Cleanup() {
+	if scratchPath != "" {
+		os.RemoveAll(scratchPath)
+	}
+}
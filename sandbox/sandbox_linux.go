@@ -0,0 +1,106 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+func backends() []string {
+	return []string{Bubblewrap, Unshare}
+}
+
+func wrap(backend string, p profile, script string) ([]string, error) {
+	switch backend {
+	case Bubblewrap:
+		return bwrapArgs(p, script), nil
+	case Unshare:
+		return unshareArgs(p, script), nil
+	}
+	return nil, fmt.Errorf("unknown sandbox backend %q", backend)
+}
+
+// bwrapArgs mounts the host read-only with a fresh /tmp, hides the real home
+// behind a tmpfs and binds the writable paths on top.
+func bwrapArgs(p profile, script string) []string {
+	home := filepath.Join(p.scratch, "home")
+	args := []string{
+		"bwrap", "--die-with-parent", "--unshare-pid",
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+	}
+	if !p.network {
+		args = append(args, "--unshare-net")
+	}
+	if p.home != "" && p.home != "/" {
+		args = append(args, "--tmpfs", p.home)
+	}
+	args = append(args, "--bind", home, home)
+	for _, w := range p.writable {
+		args = append(args, "--bind", w, w)
+	}
+	return append(args,
+		"--chdir", p.workingDir,
+		"--setenv", "HOME", home,
+		"bash", "-c", script)
+}
+
+// unshareSetup runs as root of a new user and mount namespace. It stages the
+// writable paths and the scratch HOME in a fresh tmpfs, which then replaces
+// /tmp, hides the real home behind a tmpfs, binds the staged paths back and
+// remounts every other mount read-only.
+const unshareSetup = `set -e
+scratch=$1 home=$2 script=$3
+shift 3
+stage=$scratch/tmp
+mkdir -p "$stage"
+mount -t tmpfs tmpfs "$stage"
+mkdir "$stage/.home"
+mount --bind "$scratch/home" "$stage/.home"
+i=0
+for p in "$@"; do
+	mkdir -p "$stage/.keep/$i"
+	mount --bind "$p" "$stage/.keep/$i"
+	i=$((i+1))
+done
+mount --move "$stage" /tmp
+[ -z "$home" ] || mount -t tmpfs tmpfs "$home"
+mkdir -p "$scratch/home"
+mount --bind /tmp/.home "$scratch/home"
+umount /tmp/.home
+i=0
+for p in "$@"; do
+	mkdir -p "$p"
+	mount --bind "/tmp/.keep/$i" "$p"
+	umount "/tmp/.keep/$i"
+	i=$((i+1))
+done
+rm -rf /tmp/.home /tmp/.keep
+while read -r _ _ _ _ mp _; do
+	case "$mp" in /proc|/proc/*|/sys|/sys/*|/dev|/dev/*|/tmp) continue ;; esac
+	skip=
+	for p in "$home" "$scratch/home" "$@"; do [ "$mp" != "$p" ] || skip=1; done
+	[ -n "$skip" ] || mount -o remount,bind,ro "$mp" 2>/dev/null || true
+done < /proc/self/mountinfo
+cd "$1"
+HOME=$scratch/home exec bash -c "$script"
+`
+
+func unshareArgs(p profile, script string) []string {
+	args := []string{
+		"unshare", "--user", "--map-root-user", "--mount",
+		"--pid", "--fork", "--kill-child", "--mount-proc",
+	}
+	if !p.network {
+		args = append(args, "--net")
+	}
+	home := p.home
+	if home == "/" {
+		home = ""
+	}
+	args = append(args, "bash", "-c", unshareSetup, "go-tui-sandbox", p.scratch, home, script)
+	return append(args, p.writable...)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sandbox
+
+import "fmt"
+
+func backends() []string {
+	return nil
+}
+
+func wrap(backend string, p profile, script string) ([]string, error) {
+	return nil, fmt.Errorf("sandbox is only supported on Linux")
+}
@@ -1,9 +1,12 @@
 package tui
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	"go-tui/agent"
@@ -17,6 +20,7 @@ import (
 
 type LLMResponseMsg struct {
 	Content   string
+	Reasoning string
 	ToolCalls []llm.ToolCall
 	Usage     *llm.Usage
 	Err       error
@@ -27,20 +31,44 @@ type ToolResultMsg struct {
 	ToolName   string
 	Args       string
 	Result     string
+	Changes    []tools.FileChange // the changes the tool reported, if any
 	Err        error
 }
 
-// StreamTokenCountMsg is sent periodically during streaming to update the token counter.
-type StreamTokenCountMsg struct {
-	Count    int
-	Thinking bool
-	ch       <-chan tea.Msg
+// ToolBatchResultMsg carries the results of tool calls that ran concurrently,
+// in the order the calls were made.
+type ToolBatchResultMsg struct {
+	Results []ToolResultMsg
+}
+
+// StreamDeltaMsg is sent periodically during streaming with the text received
+// so far and an estimated token count.
+type StreamDeltaMsg struct {
+	Count     int
+	Thinking  bool
+	Content   string
+	Reasoning string
+	ch        <-chan tea.Msg
+}
+
+// RetryMsg is sent when an LLM request failed transiently and will be retried.
+type RetryMsg struct {
+	Attempt     int
+	MaxAttempts int
+	Until       time.Time
+	Reason      string
+	ch          <-chan tea.Msg
 }
 
 type CompactResultMsg struct {
 	Summary string
 	Usage   *llm.Usage
 	Err     error
+	// Auto is set for compactions triggered before an LLM request; the turn
+	// continues once the result is applied.
+	Auto bool
+	// Boundary is the history index where the part kept verbatim starts.
+	Boundary int
 }
 
 type PermissionDecision int
@@ -53,7 +81,7 @@ const (
 
 // Cmd factories
 
-func callLLM(a *agent.Agent, history []llm.Message) tea.Cmd {
+func callLLM(ctx context.Context, p llm.Provider, a *agent.Agent, history []llm.Message) tea.Cmd {
 	messages := make([]llm.Message, 0, len(history)+1)
 	messages = append(messages, llm.Message{
 		Role:    "system",
@@ -66,36 +94,57 @@ func callLLM(a *agent.Agent, history []llm.Message) tea.Cmd {
 	go func() {
 		defer close(ch)
 
-		var wordCount int64
-		var thinking int32
+		// onContent and the retry observer are only called from the
+		// ChatStream goroutine
+		var tokenCount int
+		var content, reasoning strings.Builder
 
-		onContent := func(content string, isThinking bool) {
+		ctx := llm.WithRetryObserver(ctx, func(ev llm.RetryEvent) {
+			// The next attempt starts the answer over
+			tokenCount = 0
+			content.Reset()
+			reasoning.Reset()
+			ch <- RetryMsg{
+				Attempt:     ev.Attempt,
+				MaxAttempts: ev.MaxAttempts,
+				Until:       time.Now().Add(ev.Delay),
+				Reason:      retryReason(ev.Err),
+				ch:          ch,
+			}
+		})
+
+		onContent := func(delta string, isThinking bool) {
 			if isThinking {
-				atomic.StoreInt32(&thinking, 1)
+				reasoning.WriteString(delta)
 			} else {
-				atomic.StoreInt32(&thinking, 0)
+				content.WriteString(delta)
 			}
-			words := int64(len(strings.Fields(content)))
-			total := atomic.AddInt64(&wordCount, words)
-			estimated := int(float64(total) * 0.75)
+			tokenCount += llm.EstimateTokens(p.Model(), delta)
 			select {
-			case ch <- StreamTokenCountMsg{
-				Count:    estimated,
-				Thinking: atomic.LoadInt32(&thinking) == 1,
-				ch:       ch,
+			case ch <- StreamDeltaMsg{
+				Count:     tokenCount,
+				Thinking:  isThinking,
+				Content:   content.String(),
+				Reasoning: reasoning.String(),
+				ch:        ch,
 			}:
 			default:
 			}
 		}
 
-		result, err := llm.CallLLMStream(messages, tools.All(), onContent)
+		result, err := p.ChatStream(ctx, messages, a.Tools(), onContent)
 		if err != nil {
 			log.Printf("llm error: %v", err)
-			ch <- LLMResponseMsg{Err: err}
+			msg := LLMResponseMsg{Err: err}
+			if result != nil && result.Delta != nil {
+				msg.Content = result.Delta.Content
+			}
+			ch <- msg
 			return
 		}
 		ch <- LLMResponseMsg{
 			Content:   result.Delta.Content,
+			Reasoning: result.Delta.ReasoningContent,
 			ToolCalls: result.Delta.ToolCalls,
 			Usage:     result.Usage,
 		}
@@ -104,6 +153,22 @@ func callLLM(a *agent.Agent, history []llm.Message) tea.Cmd {
 	return waitForStream(ch)
 }
 
+// retryReason returns a short description of a retryable error for the status line.
+func retryReason(err error) string {
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == 429 {
+			return "rate limited"
+		}
+		return fmt.Sprintf("HTTP %d", apiErr.StatusCode)
+	}
+	var streamErr *llm.StreamEventError
+	if errors.As(err, &streamErr) {
+		return streamErr.Type
+	}
+	return "connection error"
+}
+
 func waitForStream(ch <-chan tea.Msg) tea.Cmd {
 	return func() tea.Msg {
 		time.Sleep(100 * time.Millisecond)
@@ -115,7 +180,7 @@ func waitForStream(ch <-chan tea.Msg) tea.Cmd {
 					return latest
 				}
 				latest = msg
-				if _, isToken := msg.(StreamTokenCountMsg); !isToken {
+				if _, isDelta := msg.(StreamDeltaMsg); !isDelta {
 					return msg
 				}
 			default:
@@ -132,61 +197,122 @@ func waitForStream(ch <-chan tea.Msg) tea.Cmd {
 	}
 }
 
-func compactHistory(history []llm.Message) tea.Cmd {
+// compactHistory summarizes the whole history. focus, if set, tells the
+// summarizer what to preserve in most detail.
+func compactHistory(ctx context.Context, p llm.Provider, history []llm.Message, focus string) tea.Cmd {
 	return func() tea.Msg {
-		messages := []llm.Message{
-			{
-				Role:    "system",
-				Content: "You are a conversation summarizer. Produce a concise summary of the following conversation. Preserve key decisions, code changes, file paths, and important context. Output only the summary, no preamble.",
-			},
-		}
-		// Append the full history as a single user message for context
-		var sb strings.Builder
-		for _, msg := range history {
-			sb.WriteString("[" + msg.Role + "]: ")
-			sb.WriteString(msg.Content)
-			sb.WriteString("\n\n")
-		}
-		messages = append(messages, llm.Message{
-			Role:    "user",
-			Content: sb.String(),
-		})
+		summary, usage, err := summarizeHistory(ctx, p, history, focus)
+		return CompactResultMsg{Summary: summary, Usage: usage, Err: err, Boundary: len(history)}
+	}
+}
 
-		result, err := llm.CallLLM(messages, nil)
-		if err != nil {
-			log.Printf("compact error: %v", err)
-			return CompactResultMsg{Err: err}
+// autoCompactHistory summarizes history[:boundary]; the rest is kept verbatim.
+func autoCompactHistory(ctx context.Context, p llm.Provider, history []llm.Message, boundary int) tea.Cmd {
+	return func() tea.Msg {
+		summary, usage, err := summarizeHistory(ctx, p, history[:boundary], "")
+		return CompactResultMsg{Summary: summary, Usage: usage, Err: err, Auto: true, Boundary: boundary}
+	}
+}
+
+const summarizerPrompt = `You are a conversation summarizer for a coding assistant. The conversation below has been condensed: tool calls are shown with their arguments and tool results are reduced to what they did. Write a summary the assistant can continue the work from, with these sections:
+
+- Goal: what the user asked for
+- Decisions: choices made and constraints the user stated
+- Changes: what was changed and why, by file
+- State: what is done, what failed, and what remains
+
+Be concise and keep file paths, identifiers and error messages exact. Output only the summary, no preamble.`
+
+// summarizeHistory condenses history and asks the model for a summary of it.
+// The ledger of files and tasks is appended to the summary as-is, so it is
+// kept even if the model leaves it out.
+func summarizeHistory(ctx context.Context, p llm.Provider, history []llm.Message, focus string) (string, *llm.Usage, error) {
+	transcript, ledger := condenseHistory(history)
+
+	system := summarizerPrompt
+	if focus != "" {
+		system += "\n\nThe user asked to focus the summary on: " + focus + ". Keep details relevant to it and shorten the rest."
+	}
+	content := transcript
+	if l := ledger.render(); l != "" {
+		content += "[ledger]:\n" + l + "\n"
+	}
+	messages := []llm.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: content},
+	}
+
+	result, err := p.Chat(ctx, messages, nil)
+	if err != nil {
+		log.Printf("compact error: %v", err)
+		return "", nil, err
+	}
+	summary := strings.TrimSpace(result.Delta.Content)
+	if l := ledger.render(); l != "" {
+		summary += "\n\n" + l
+	}
+	return summary, result.Usage, nil
+}
+
+func executeTool(ctx context.Context, a *agent.Agent, tc llm.ToolCall) tea.Cmd {
+	return func() tea.Msg {
+		return runTool(ctx, a, tc)
+	}
+}
+
+// executeToolBatch runs calls concurrently and reports all results at once.
+func executeToolBatch(ctx context.Context, a *agent.Agent, calls []llm.ToolCall) tea.Cmd {
+	return func() tea.Msg {
+		results := make([]ToolResultMsg, len(calls))
+		var wg sync.WaitGroup
+		for i, tc := range calls {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = runTool(ctx, a, tc)
+			}()
 		}
-		return CompactResultMsg{
-			Summary: result.Delta.Content,
-			Usage:   result.Usage,
+		wg.Wait()
+		return ToolBatchResultMsg{Results: results}
+	}
+}
+
+// denyTool reports tc as refused without running it.
+func denyTool(tc llm.ToolCall, reason string) tea.Cmd {
+	return func() tea.Msg {
+		return ToolResultMsg{
+			ToolCallID: tc.ID,
+			ToolName:   tc.Function.Name,
+			Args:       tc.Function.Arguments,
+			Result:     reason,
+			Err:        errors.New(reason),
 		}
 	}
 }
 
-func executeTool(a *agent.Agent, tc llm.ToolCall) tea.Cmd {
+// runTool executes one tool call and wraps the outcome as a ToolResultMsg.
+func runTool(ctx context.Context, a *agent.Agent, tc llm.ToolCall) ToolResultMsg {
 	name := tc.Function.Name
 	args := tc.Function.Arguments
 	id := tc.ID
 
-	return func() tea.Msg {
-		result, err := a.ExecuteTool(name, args)
-		if err != nil {
-			log.Printf("tool error: %v", err)
-			return ToolResultMsg{
-				ToolCallID: id,
-				ToolName:   name,
-				Args:       args,
-				Result:     err.Error(),
-				Err:        err,
-			}
-		}
-		log.Printf("tool result: %.200s", result.Output)
+	result, err := a.ExecuteTool(ctx, name, args)
+	if err != nil {
+		log.Printf("tool error: %v", err)
 		return ToolResultMsg{
 			ToolCallID: id,
 			ToolName:   name,
 			Args:       args,
-			Result:     result.Output,
+			Result:     err.Error(),
+			Err:        err,
 		}
 	}
+	log.Printf("tool result: %.200s", result.Output)
+	return ToolResultMsg{
+		ToolCallID: id,
+		ToolName:   name,
+		Args:       args,
+		Result:     result.Output,
+		Changes:    result.Changes,
+	}
 }
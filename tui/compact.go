@@ -0,0 +1,315 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-tui/agent/tools"
+	"go-tui/config"
+	"go-tui/conversation"
+	"go-tui/llm"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// contextWindow returns the context window of the active model.
+func (m *Model) contextWindow() int {
+	return config.Current.ContextWindow(m.provider.Model())
+}
+
+// requestLLM starts the next LLM round. When the estimated prompt would fill
+// more than the configured fraction of the context window, the older part of
+// the history is compacted first and the round continues afterwards.
+func (m *Model) requestLLM() tea.Cmd {
+	m.estimatePrompt()
+
+	policy := config.Current.Compaction
+	if policy.Auto && float64(m.totalTokens) >= policy.Threshold*float64(m.contextWindow()) {
+		if boundary := compactionBoundary(m.history, policy.KeepTurns); boundary > 0 {
+			log.Printf("auto-compacting: ~%d tokens, summarizing %d of %d messages", m.totalTokens, boundary, len(m.history))
+			m.compacting = true
+			return autoCompactHistory(m.turnCtx, m.provider, m.history, boundary)
+		}
+	}
+	return callLLM(m.turnCtx, m.provider, m.agent, m.history)
+}
+
+// compactionBoundary returns the index from which history is kept verbatim:
+// the start of the keepTurns-th most recent user turn. If there are not that
+// many turns, only the latest assistant round is kept, so a single long
+// agentic turn can still be compacted. A boundary never falls on a tool
+// message, which keeps every assistant tool call paired with its results.
+// Returns 0 when nothing can be compacted.
+func compactionBoundary(history []llm.Message, keepTurns int) int {
+	if keepTurns < 1 {
+		keepTurns = 1
+	}
+	turns := 0
+	for i := len(history) - 1; i > 0; i-- {
+		if history[i].Role == "user" {
+			turns++
+			if turns == keepTurns {
+				return i
+			}
+		}
+	}
+	for i := len(history) - 1; i > 0; i-- {
+		if history[i].Role == "assistant" {
+			return i
+		}
+	}
+	return 0
+}
+
+// applyCompaction replaces history[:boundary] with summary and records where
+// the compaction happened. UI messages are kept; a notice marks the spot.
+func (m *Model) applyCompaction(summary string, boundary int, auto bool) {
+	kept := append([]llm.Message(nil), m.history[boundary:]...)
+	m.history = append([]llm.Message{{
+		Role:    "user",
+		Content: "[Conversation summary]\n" + summary,
+	}}, kept...)
+
+	m.conv.Compactions = append(m.conv.Compactions, conversation.Compaction{
+		Time:               time.Now(),
+		Auto:               auto,
+		MessageIndex:       len(m.messages),
+		SummarizedMessages: boundary,
+		KeptMessages:       len(kept),
+		TokensBefore:       m.totalTokens,
+	})
+
+	how := "Conversation compacted"
+	if auto {
+		how = "Context nearly full, conversation compacted automatically"
+	}
+	m.messages = append(m.messages, ChatEntry{
+		Type:    EntryNotice,
+		Content: fmt.Sprintf("%s: summarized %d messages, kept %d verbatim", how, boundary, len(kept)),
+	})
+}
+
+// Limits for condensed tool output in the compaction transcript.
+const (
+	condensedArgLen   = 120
+	condensedOutLines = 8
+)
+
+var (
+	readHeaderRe   = regexp.MustCompile(`^File: (.+) \((\d+) total lines, showing (\d+)-(\d+)\)`)
+	createdIssueRe = regexp.MustCompile(`Created issue:\s*(\S+)`)
+)
+
+// compactLedger tracks what a conversation touched, so the facts survive
+// compaction even when the model's summary leaves them out.
+type compactLedger struct {
+	read     []string
+	modified []string
+	tasks    []string // IDs or titles of tasks created and not yet closed
+	closed   map[string]bool
+}
+
+func addUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// beadsTask updates the task list from one beads call and its output.
+func (l *compactLedger) beadsTask(args tools.BeadsArgs, output string) {
+	fields := strings.Fields(args.Args)
+	switch strings.Fields(args.Command + " x")[0] {
+	case "create":
+		task := strings.TrimSpace(args.Args)
+		if m := createdIssueRe.FindStringSubmatch(output); m != nil {
+			task = m[1] + " " + task
+		}
+		if task != "" {
+			l.tasks = addUnique(l.tasks, task)
+		}
+	case "close":
+		for _, id := range fields {
+			l.closed[id] = true
+		}
+	case "update":
+		if len(fields) > 0 && strings.Contains(args.Args, "closed") {
+			l.closed[fields[0]] = true
+		}
+	}
+}
+
+// openTasks returns tasks that were created and not closed.
+func (l *compactLedger) openTasks() []string {
+	var open []string
+	for _, t := range l.tasks {
+		id, _, _ := strings.Cut(t, " ")
+		if !l.closed[id] {
+			open = append(open, t)
+		}
+	}
+	return open
+}
+
+// render formats the ledger as a markdown section, or "" when it is empty.
+func (l *compactLedger) render() string {
+	var sb strings.Builder
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		sb.WriteString(title + ":\n")
+		for _, item := range items {
+			sb.WriteString("- " + item + "\n")
+		}
+	}
+	section("Files modified", l.modified)
+	section("Files read", l.read)
+	section("Open tasks", l.openTasks())
+	return strings.TrimSpace(sb.String())
+}
+
+// condenseHistory turns history into a compact transcript for the summarizer.
+// Tool calls keep their arguments in short form and tool results are reduced
+// to what they did (a file read becomes the range that was read, an edit the
+// file that changed), since raw outputs are what fill the context window.
+func condenseHistory(history []llm.Message) (string, *compactLedger) {
+	ledger := &compactLedger{closed: map[string]bool{}}
+	calls := map[string]llm.ToolCall{}
+
+	var sb strings.Builder
+	for _, msg := range history {
+		switch msg.Role {
+		case "assistant":
+			sb.WriteString("[assistant]: ")
+			sb.WriteString(msg.Content)
+			for _, tc := range msg.ToolCalls {
+				calls[tc.ID] = tc
+				sb.WriteString("\n  → " + condenseCall(tc))
+			}
+		case "tool":
+			tc := calls[msg.ToolCallID]
+			sb.WriteString("[tool " + tc.Function.Name + "]: ")
+			sb.WriteString(condenseResult(tc, msg.Content, ledger))
+		default:
+			sb.WriteString("[" + msg.Role + "]: ")
+			sb.WriteString(msg.Content)
+		}
+		sb.WriteString("\n\n")
+	}
+	return sb.String(), ledger
+}
+
+// condenseCall renders a tool call as name(arguments) with long values cut.
+func condenseCall(tc llm.ToolCall) string {
+	var args map[string]any
+	if json.Unmarshal([]byte(tc.Function.Arguments), &args) != nil {
+		return tc.Function.Name + "(" + truncateRunes(tc.Function.Arguments, condensedArgLen) + ")"
+	}
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := fmt.Sprint(args[k])
+		if s, ok := args[k].(string); ok {
+			if n := strings.Count(s, "\n"); n > 0 {
+				v = fmt.Sprintf("<%d lines>", n+1)
+			} else {
+				v = strconv.Quote(truncateRunes(s, condensedArgLen))
+			}
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return tc.Function.Name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// condenseResult reduces one tool result and records it in the ledger.
+func condenseResult(tc llm.ToolCall, output string, ledger *compactLedger) string {
+	args := tc.Function.Arguments
+	switch tc.Function.Name {
+	case "read_file":
+		if m := readHeaderRe.FindStringSubmatch(output); m != nil {
+			ledger.read = addUnique(ledger.read, m[1])
+			return fmt.Sprintf("read %s lines %s-%s of %s", m[1], m[3], m[4], m[2])
+		}
+	case "edit_file", "multi_edit", "write_file":
+		var r struct {
+			FilePath    string `json:"file_path"`
+			LSPFeedback string `json:"lsp_feedback"`
+		}
+		if json.Unmarshal([]byte(output), &r) == nil && r.FilePath != "" {
+			ledger.modified = addUnique(ledger.modified, r.FilePath)
+			verb := "edited"
+			if tc.Function.Name == "write_file" {
+				verb = "wrote"
+			}
+			if r.LSPFeedback != "" {
+				return verb + " " + r.FilePath + "; " + condenseOutput(r.LSPFeedback)
+			}
+			return verb + " " + r.FilePath
+		}
+	case "apply_patch":
+		var r tools.ApplyPatchResult
+		if json.Unmarshal([]byte(output), &r) == nil && len(r.Files) > 0 {
+			var names []string
+			for _, f := range r.Files {
+				ledger.modified = addUnique(ledger.modified, f.FilePath)
+				names = append(names, f.FilePath)
+			}
+			summary := "patched " + strings.Join(names, ", ")
+			if r.LSPFeedback != "" {
+				return summary + "; " + condenseOutput(r.LSPFeedback)
+			}
+			return summary
+		}
+	case "bash":
+		var r tools.BashResult
+		if json.Unmarshal([]byte(output), &r) == nil {
+			status := fmt.Sprintf("exit %d", r.ExitCode)
+			if r.TimedOut {
+				status = "timed out"
+			} else if r.Signal != "" {
+				status = r.Signal
+			}
+			return status + "\n" + condenseOutput(r.Output)
+		}
+	case "beads":
+		var a tools.BeadsArgs
+		if json.Unmarshal([]byte(args), &a) == nil {
+			ledger.beadsTask(a, output)
+		}
+	}
+	return condenseOutput(output)
+}
+
+// condenseOutput keeps the first and last few lines of output, where
+// commands usually report what they did and how they ended.
+func condenseOutput(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= condensedOutLines {
+		return strings.Join(lines, "\n")
+	}
+	half := condensedOutLines / 2
+	return strings.Join(lines[:half], "\n") +
+		fmt.Sprintf("\n… (%d lines omitted)\n", len(lines)-2*half) +
+		strings.Join(lines[len(lines)-half:], "\n")
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
@@ -71,21 +71,16 @@ func renderDiff(d DiffData) string {
 	return strings.TrimRight(sb.String(), "\n")
 }
 
-// findStartLine returns the 1-based line number where needle starts within fileContent.
-// Returns 1 if not found.
-func findStartLine(fileContent, needle string) int {
-	idx := strings.Index(fileContent, needle)
-	if idx < 0 {
-		return 1
+// renderDiffs renders the diffs of several files one after another.
+func renderDiffs(diffs []DiffData) string {
+	rendered := make([]string, len(diffs))
+	for i, d := range diffs {
+		rendered[i] = renderDiff(d)
 	}
-	return strings.Count(fileContent[:idx], "\n") + 1
+	return strings.Join(rendered, "\n\n")
 }
 
 // getDiffForPermission renders a diff preview for the permission prompt.
 func getDiffForPermission(toolName, argsJSON, workingDir string) string {
-	d := parseDiffFromArgs(toolName, argsJSON, workingDir)
-	if d == nil {
-		return ""
-	}
-	return renderDiff(*d)
+	return renderDiffs(parseDiffFromArgs(toolName, argsJSON, workingDir))
 }
@@ -3,15 +3,19 @@ package tui
 import (
 	"fmt"
 	"strings"
+
+	"go-tui/agent/tools"
 )
 
+// canGroupToolCall reports whether entry is a read-only call that can be
+// folded into a summary line with its neighbours.
 func canGroupToolCall(entry ChatEntry) bool {
 	if entry.Type != EntryToolCall || entry.Denied {
 		return false
 	}
 
 	name, _ := splitCommand(entry.Command)
-	return name == "read_file" || name == "list_files" || name == "search"
+	return tools.InfoFor(name).ReadOnly
 }
 
 func findGroupEnd(messages []ChatEntry, start int) int {
@@ -26,33 +30,36 @@ func findGroupEnd(messages []ChatEntry, start int) int {
 	return end
 }
 
-func countOperations(group []ChatEntry) (reads, searches, lists int) {
+// groupLabels describes a number of grouped calls of each category.
+var groupLabels = map[tools.Category]string{
+	tools.CategoryRead:   "Read %d files",
+	tools.CategorySearch: "Searched for %d patterns",
+	tools.CategoryList:   "Listed %d directories",
+	tools.CategoryFind:   "Found files for %d patterns",
+}
+
+func renderGroupedToolCalls(group []ChatEntry) string {
+	// Count calls per category, keeping the order categories first appear in
+	counts := map[tools.Category]int{}
+	icons := map[tools.Category]string{}
+	var order []tools.Category
 	for _, entry := range group {
 		name, _ := splitCommand(entry.Command)
-		switch name {
-		case "read_file":
-			reads++
-		case "search":
-			searches++
-		case "list_files":
-			lists++
+		info := tools.InfoFor(name)
+		if counts[info.Category] == 0 {
+			order = append(order, info.Category)
+			icons[info.Category] = info.Icon
 		}
+		counts[info.Category]++
 	}
-	return reads, searches, lists
-}
-
-func renderGroupedToolCalls(group []ChatEntry) string {
-	reads, searches, lists := countOperations(group)
 
 	var parts []string
-	if reads > 0 {
-		parts = append(parts, fmt.Sprintf("📖 Read %d files", reads))
-	}
-	if searches > 0 {
-		parts = append(parts, fmt.Sprintf("🔍 Searched for %d patterns", searches))
-	}
-	if lists > 0 {
-		parts = append(parts, fmt.Sprintf("📁 Listed %d directories", lists))
+	for _, c := range order {
+		label, ok := groupLabels[c]
+		if !ok {
+			label = "Ran %d tools"
+		}
+		parts = append(parts, icons[c]+fmt.Sprintf(label, counts[c]))
 	}
 
 	header := toolBulletStyle.Render("⏺ ") + toolCmdStyle.Render(strings.Join(parts, ", "))
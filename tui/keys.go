@@ -1,9 +1,11 @@
 package tui
 
 import (
+	"log"
 	"strings"
 
 	"go-tui/llm"
+	"go-tui/permission"
 	"go-tui/tui/slashcmd"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,6 +15,10 @@ func handleKeyMsg(m *Model, msg tea.KeyMsg) (*Model, tea.Cmd) {
 	switch msg.Type {
 	case tea.KeyCtrlC:
 		return m, tea.Quit
+	case tea.KeyCtrlO:
+		m.showThinking = !m.showThinking
+		m.refreshViewport()
+		return m, nil
 	}
 
 	// Permission prompt mode
@@ -64,9 +70,17 @@ func handleKeyMsg(m *Model, msg tea.KeyMsg) (*Model, tea.Cmd) {
 		m.toolRoundCount = 0
 		m.consecutiveErrors = 0
 
+		m.startTurn()
+		cmd := m.requestLLM()
 		m.refreshViewport()
 
-		return m, callLLM(m.agent, m.history)
+		return m, cmd
+
+	case tea.KeyEsc:
+		if m.waiting {
+			m.interrupt()
+			return m, nil
+		}
 	}
 
 	if m.waiting {
@@ -205,15 +219,23 @@ func handlePermissionKey(m *Model, msg tea.KeyMsg) (*Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyDown:
-		if m.permission.Cursor < 2 {
+		if m.permission.Cursor < len(m.permission.Options)-1 {
 			m.permission.Cursor++
 			m.refreshViewport()
 		}
 		return m, nil
 
+	case tea.KeyEsc:
+		// Nothing is running while the prompt is open, so end the turn directly
+		m.permission = nil
+		m.awaitingPermission = nil
+		m.interrupt()
+		m.recordInterrupt()
+		return m, nil
+
 	case tea.KeyEnter:
-		// Read cursor and tool call before clearing permission state
-		cursor := m.permission.Cursor
+		// Read the choice and tool call before clearing permission state
+		opt := m.permission.Options[m.permission.Cursor]
 		tc := m.awaitingPermission
 
 		// Clear permission state
@@ -221,15 +243,30 @@ func handlePermissionKey(m *Model, msg tea.KeyMsg) (*Model, tea.Cmd) {
 		m.awaitingPermission = nil
 		m.refreshViewport()
 
-		switch cursor {
-		case 0: // Allow
-			return m, executeTool(m.agent, *tc)
-
-		case 1: // Always Allow
-			m.alwaysAllow[tc.Function.Name] = true
-			return m, executeTool(m.agent, *tc)
-
-		case 2: // Deny
+		switch opt.Choice {
+		case choiceAllow:
+			return m, executeTool(m.turnCtx, m.agent, *tc)
+
+		case choiceAllowRule:
+			if err := permission.Current.AddAllow(opt.Rules...); err != nil {
+				log.Printf("saving permission rule: %v", err)
+				m.messages = append(m.messages, ChatEntry{
+					Type:    EntryError,
+					Content: "Could not save permission rule: " + err.Error(),
+				})
+				m.refreshViewport()
+			}
+			return m, executeTool(m.turnCtx, m.agent, *tc)
+
+		case choiceAllowSession:
+			if len(opt.Rules) > 0 {
+				permission.Current.AllowForSession(opt.Rules...)
+			} else {
+				m.alwaysAllow[tc.Function.Name] = true
+			}
+			return m, executeTool(m.turnCtx, m.agent, *tc)
+
+		case choiceDeny:
 			command := tc.Function.Name + ": " + tc.Function.Arguments
 			result := "Tool call denied by user."
 
@@ -245,7 +282,7 @@ func handlePermissionKey(m *Model, msg tea.KeyMsg) (*Model, tea.Cmd) {
 				Type:    EntryToolCall,
 				Command: command,
 				Denied:  true,
-				Diff:    parseDiffFromToolCall(tc.Function.Name, tc.Function.Arguments, "", m.workingDir, true),
+				Diffs:   parseDiffFromToolCall(tc.Function.Name, tc.Function.Arguments, "", m.workingDir, true),
 			})
 
 			// Stop the loop — return to user input
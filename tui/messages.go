@@ -5,13 +5,15 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
+	"go-tui/agent/tools"
 	"go-tui/config"
 )
 
 // Styles are defined in theme.go
 
-func renderMessages(messages []ChatEntry, perm *PermissionPrompt, width int, md *MarkdownRenderer) string {
+func renderMessages(messages []ChatEntry, perm *PermissionPrompt, width int, md *MarkdownRenderer, showThinking bool) string {
 	if len(messages) == 0 && perm == nil {
 		return "Welcome! Type a message and press Enter to send."
 	}
@@ -58,12 +60,23 @@ func renderMessages(messages []ChatEntry, perm *PermissionPrompt, width int, md
 				} else {
 					rendered = entry.Content
 				}
+				if entry.Reasoning != "" {
+					thinking := renderThinking(entry.Reasoning, showThinking, false, width)
+					if rendered == "" {
+						rendered = thinking
+					} else {
+						rendered = thinking + "\n" + rendered
+					}
+				}
 			default:
 				rendered = fmt.Sprintf("%s: %s", entry.Role, entry.Content)
 			}
 
 		case EntryError:
 			rendered = errorStyle.Render("Error: " + entry.Content)
+
+		case EntryNotice:
+			rendered = indentBlock(noticeStyle.Render(entry.Content))
 		}
 
 		rendered = strings.Trim(rendered, "\n")
@@ -101,19 +114,29 @@ func renderToolCallEntry(entry ChatEntry) string {
 		return bullet + " " + deniedStyle.Render("User declined")
 	}
 
-	if entry.Diff != nil {
-		return bullet + "\n" + indentBlock(renderDiff(*entry.Diff))
+	if diffs := entry.diffs(); len(diffs) > 0 {
+		return bullet + "\n" + indentBlock(renderDiffs(diffs))
 	}
 
 	name, _ := splitCommand(entry.Command)
 
-	// For read_file, just show the bullet header
-	if name == "read_file" {
+	// File contents are too long to be useful; just show the bullet header
+	if tools.InfoFor(name).Category == tools.CategoryRead {
 		return bullet
 	}
 
 	// Default: show bullet + indented result
 	result := entry.Result
+	if name == "bash" {
+		var r tools.BashResult
+		if json.Unmarshal([]byte(result), &r) == nil {
+			bullet += " " + exitBadge(r)
+			if strings.TrimSpace(r.Output) == "" {
+				return bullet
+			}
+			result = r.Output
+		}
+	}
 	maxResultLines := config.MaxResultLines
 	lines := strings.Split(result, "\n")
 	if len(lines) > maxResultLines {
@@ -122,6 +145,23 @@ func renderToolCallEntry(entry ChatEntry) string {
 	return bullet + "\n" + indentBlock(result)
 }
 
+// exitBadge summarizes how a bash command ended, e.g. "✓ exit 0 · 1.2s".
+func exitBadge(r tools.BashResult) string {
+	took := time.Duration(r.DurationMs) * time.Millisecond
+	if took >= time.Second {
+		took = took.Round(100 * time.Millisecond)
+	}
+	switch {
+	case r.TimedOut:
+		return exitFailStyle.Render(fmt.Sprintf("✗ timed out · %s", took))
+	case r.Signal != "":
+		return exitFailStyle.Render(fmt.Sprintf("✗ %s · %s", r.Signal, took))
+	case r.ExitCode != 0:
+		return exitFailStyle.Render(fmt.Sprintf("✗ exit %d · %s", r.ExitCode, took))
+	}
+	return exitOKStyle.Render(fmt.Sprintf("✓ exit 0 · %s", took))
+}
+
 // indentBlock renders content with ⎿ on the first line, then spaces for the rest.
 func indentBlock(content string) string {
 	content = strings.TrimRight(content, "\n ")
@@ -138,98 +178,18 @@ func indentBlock(content string) string {
 	return out
 }
 
-// formatCommand turns "tool_name: {json}" into a human-readable string.
+// formatCommand turns "tool_name: {json}" into a human-readable string using
+// the tool's icon and formatter.
 func formatCommand(command string) string {
 	name, argsJSON := splitCommand(command)
 	if argsJSON == "" {
 		return command
 	}
-
-	var args map[string]json.RawMessage
-	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-		return command
-	}
-
-	str := func(key string) string {
-		raw, ok := args[key]
-		if !ok {
-			return ""
-		}
-		var s string
-		if json.Unmarshal(raw, &s) == nil {
-			return s
-		}
-		return ""
-	}
-
-	num := func(key string) (int, bool) {
-		raw, ok := args[key]
-		if !ok {
-			return 0, false
-		}
-		var n int
-		if json.Unmarshal(raw, &n) == nil {
-			return n, true
-		}
-		return 0, false
-	}
-
-	var icon string
-	switch name {
-	case "read_file":
-		icon = config.ReadIcon
-	case "list_files":
-		icon = config.ListIcon
-	case "bash":
-		icon = config.BashIcon
-	case "search":
-		icon = config.SearchIcon
-	case "edit_file":
-		icon = config.EditIcon
-	case "write_file":
-		icon = config.WriteIcon
-	case "beads":
-		icon = config.ToolIcon
-	default:
-		icon = config.ToolIcon
-	}
-
-	switch name {
-	case "read_file":
-		s := "Read: " + str("file_path")
-		offset, hasOffset := num("offset")
-		limit, hasLimit := num("limit")
-		if hasOffset && hasLimit {
-			s += fmt.Sprintf(" %d:%d", offset, offset+limit-1)
-		} else if hasOffset {
-			s += fmt.Sprintf(" from %d", offset)
-		} else if hasLimit {
-			s += fmt.Sprintf(" first %d lines", limit)
-		}
-		return icon + s
-	case "list_files":
-		path := str("path")
-		if path == "" {
-			path = "."
-		}
-		return icon + "List: " + path
-	case "bash":
-		return icon + "Bash: " + str("command")
-	case "search":
-		s := "Search: " + str("pattern")
-		if p := str("path"); p != "" {
-			s += " in " + p
-		}
-		return icon + s
-	case "beads":
-		s := "Beads: " + str("command")
-		if a := str("args"); a != "" {
-			s += " " + a
-		}
-		return icon + s
-	default:
-		return icon + command
+	t, ok := tools.Lookup(name)
+	if !ok {
+		return config.ToolIcon + command
 	}
+	return t.Info().Icon + t.Format(argsJSON)
 }
 
 // splitCommand splits "tool_name: {json}" into name and argsJSON.
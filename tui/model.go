@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,9 +11,11 @@ import (
 	"time"
 
 	"go-tui/agent"
+	"go-tui/agent/tools"
 	"go-tui/config"
 	"go-tui/conversation"
 	"go-tui/llm"
+	"go-tui/permission"
 	"go-tui/tui/slashcmd"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -28,6 +31,7 @@ const (
 	EntryMessage EntryType = iota
 	EntryToolCall
 	EntryError
+	EntryNotice
 )
 
 type DiffData struct {
@@ -38,13 +42,23 @@ type DiffData struct {
 }
 
 type ChatEntry struct {
-	Type    EntryType `json:"type"`
-	Role    string    `json:"role,omitempty"`
-	Content string    `json:"content,omitempty"`
-	Command string    `json:"command,omitempty"`
-	Result  string    `json:"result,omitempty"`
-	Denied  bool      `json:"denied,omitempty"`
-	Diff    *DiffData `json:"diff,omitempty"`
+	Type      EntryType  `json:"type"`
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	Reasoning string     `json:"reasoning,omitempty"`
+	Command   string     `json:"command,omitempty"`
+	Result    string     `json:"result,omitempty"`
+	Denied    bool       `json:"denied,omitempty"`
+	Diffs     []DiffData `json:"diffs,omitempty"`
+	Diff      *DiffData  `json:"diff,omitempty"` // the single diff of conversations saved before Diffs
+}
+
+// diffs returns the file changes to show for the entry.
+func (e ChatEntry) diffs() []DiffData {
+	if e.Diff != nil {
+		return append([]DiffData{*e.Diff}, e.Diffs...)
+	}
+	return e.Diffs
 }
 
 const maxToolRounds = config.MaxToolRounds
@@ -56,6 +70,7 @@ type Model struct {
 	spinner            spinner.Model
 	messages           []ChatEntry
 	agent              *agent.Agent
+	provider           llm.Provider
 	waiting            bool
 	width              int
 	height             int
@@ -73,15 +88,24 @@ type Model struct {
 	pendingToolIndex   int
 	awaitingPermission *llm.ToolCall
 	totalTokens        int
+	promptEstimate     int
+	tokenScale         float64
 	streamingTokens    int
 	streamingThinking  bool
+	stream             streamView
+	showThinking       bool
+	baseView           string
+	retry              *RetryMsg
+	compacting         bool
 	slashOverlay       *slashcmd.Overlay
 	rewindOverlay      *slashcmd.RewindOverlay
+	turnCtx            context.Context
+	cancelTurn         context.CancelFunc
 }
 
 // separatorStyle and statusStyle are defined in theme.go
 
-func New(workingDir string, conv *conversation.Data) Model {
+func New(workingDir string, conv *conversation.Data, provider llm.Provider) Model {
 	ta := textarea.New()
 	ta.Placeholder = "Type a message..."
 	ta.Focus()
@@ -116,12 +140,16 @@ func New(workingDir string, conv *conversation.Data) Model {
 	if err := json.Unmarshal(conv.AgentHistory, &history); err != nil {
 		log.Printf("failed to unmarshal agent history: %v", err)
 	}
+	if conv.Usage == nil {
+		conv.Usage = &conversation.UsageTotals{}
+	}
 
 	return Model{
 		textarea:         ta,
 		spinner:          s,
 		messages:         messages,
 		agent:            a,
+		provider:         provider,
 		conv:             conv,
 		convDir:          conversation.Dir(workingDir),
 		markdownRenderer: markdownRenderer,
@@ -132,9 +160,64 @@ func New(workingDir string, conv *conversation.Data) Model {
 }
 
 func (m *Model) Shutdown() {
+	if m.cancelTurn != nil {
+		m.cancelTurn()
+	}
 	m.agent.Shutdown()
 }
 
+// startTurn creates a fresh cancellable context for the LLM requests and tool
+// runs that follow a user action. Esc cancels it via interrupt.
+func (m *Model) startTurn() context.Context {
+	if m.cancelTurn != nil {
+		m.cancelTurn()
+	}
+	m.turnCtx, m.cancelTurn = context.WithCancel(context.Background())
+	return m.turnCtx
+}
+
+// interrupted reports whether the user cancelled the current turn.
+func (m *Model) interrupted() bool {
+	return m.turnCtx != nil && m.turnCtx.Err() != nil
+}
+
+// interrupt cancels the in-flight LLM request or tool run. The cancelled
+// command reports back through Update, which calls recordInterrupt.
+func (m *Model) interrupt() {
+	if m.cancelTurn != nil {
+		log.Printf("turn interrupted by user")
+		m.cancelTurn()
+	}
+}
+
+// recordInterrupt ends the current turn after an interrupt. Every pending tool
+// call still gets a result so the assistant's tool_calls stay paired with tool
+// messages, which the API requires.
+func (m *Model) recordInterrupt() {
+	for i := m.pendingToolIndex; i < len(m.pendingToolCalls); i++ {
+		m.history = append(m.history, llm.Message{
+			Role:       "tool",
+			Content:    "Tool call interrupted by user.",
+			ToolCallID: m.pendingToolCalls[i].ID,
+		})
+	}
+	m.pendingToolCalls = nil
+	m.pendingToolIndex = 0
+
+	m.history = append(m.history, llm.Message{
+		Role:    "user",
+		Content: "[Request interrupted by user]",
+	})
+	m.messages = append(m.messages, ChatEntry{
+		Type:    EntryNotice,
+		Content: "Interrupted by user",
+	})
+	m.waiting = false
+	m.textarea.Focus()
+	m.saveConversation()
+	m.refreshViewport()
+}
+
 func (m *Model) saveConversation() {
 	uiJSON, err := json.Marshal(m.messages)
 	if err != nil {
@@ -153,99 +236,42 @@ func (m *Model) saveConversation() {
 	}
 }
 
-func parseDiffFromToolCall(toolName, args, result, workingDir string, denied bool) *DiffData {
+// parseDiffFromToolCall returns the file changes a tool call made, for
+// display. Denied calls show the changes they would have made.
+func parseDiffFromToolCall(toolName, args, result, workingDir string, denied bool) []DiffData {
 	if denied {
 		return parseDiffFromArgs(toolName, args, workingDir)
 	}
-
 	if result == "" {
 		return nil
 	}
+	return diffFromChanges(toolName, args, result, workingDir)
+}
 
-	switch toolName {
-	case "edit_file", "write_file":
-		var r struct {
-			FilePath   string `json:"file_path"`
-			OldString  string `json:"old_string"`
-			NewString  string `json:"new_string"`
-			OldContent string `json:"old_content"`
-			NewContent string `json:"new_content"`
-			IsNewFile  bool   `json:"is_new_file"`
-		}
-		if json.Unmarshal([]byte(result), &r) != nil || r.FilePath == "" {
-			return parseDiffFromArgs(toolName, args, workingDir)
-		}
-		old := r.OldString + r.OldContent
-		new_ := r.NewString + r.NewContent
-		startLine := 1
-		if toolName == "edit_file" {
-			path := r.FilePath
-			if !filepath.IsAbs(path) {
-				path = filepath.Join(workingDir, path)
-			}
-			if data, err := os.ReadFile(path); err == nil {
-				startLine = findStartLine(string(data), r.OldString)
-			}
-		}
-		return &DiffData{
-			FilePath:  r.FilePath,
-			OldText:   old,
-			NewText:   new_,
-			StartLine: startLine,
-		}
-	}
-	return nil
+// parseDiffFromArgs previews the file changes a tool call would make.
+func parseDiffFromArgs(name, argsJSON, workingDir string) []DiffData {
+	return diffFromChanges(name, argsJSON, "", workingDir)
 }
 
-func parseDiffFromArgs(name, argsJSON, workingDir string) *DiffData {
-	switch name {
-	case "edit_file":
-		var args struct {
-			FilePath  string `json:"file_path"`
-			OldString string `json:"old_string"`
-			NewString string `json:"new_string"`
-		}
-		if json.Unmarshal([]byte(argsJSON), &args) != nil || args.FilePath == "" {
-			return nil
-		}
-		startLine := 1
-		path := args.FilePath
-		if !filepath.IsAbs(path) {
-			path = filepath.Join(workingDir, path)
-		}
-		if data, err := os.ReadFile(path); err == nil {
-			startLine = findStartLine(string(data), args.OldString)
-		}
-		return &DiffData{
-			FilePath:  args.FilePath,
-			OldText:   args.OldString,
-			NewText:   args.NewString,
-			StartLine: startLine,
-		}
+func diffFromChanges(name, argsJSON, result, workingDir string) []DiffData {
+	t, ok := tools.Lookup(name)
+	if !ok {
+		return nil
+	}
+	return diffData(t.Changes(argsJSON, result, workingDir))
+}
 
-	case "write_file":
-		var args struct {
-			FilePath string `json:"file_path"`
-			Content  string `json:"content"`
-		}
-		if json.Unmarshal([]byte(argsJSON), &args) != nil || args.FilePath == "" {
-			return nil
-		}
-		path := args.FilePath
-		if !filepath.IsAbs(path) {
-			path = filepath.Join(workingDir, path)
-		}
-		d := &DiffData{
-			FilePath:  args.FilePath,
-			NewText:   args.Content,
-			StartLine: 1,
-		}
-		if data, err := os.ReadFile(path); err == nil {
-			d.OldText = string(data)
-		}
-		return d
+func diffData(changes []tools.FileChange) []DiffData {
+	var diffs []DiffData
+	for _, c := range changes {
+		diffs = append(diffs, DiffData{
+			FilePath:  c.FilePath,
+			OldText:   c.OldText,
+			NewText:   c.NewText,
+			StartLine: c.StartLine,
+		})
 	}
-	return nil
+	return diffs
 }
 
 func (m *Model) Init() tea.Cmd {
@@ -258,8 +284,26 @@ func (m *Model) updateMarkdownRenderer() {
 	}
 }
 
+// refreshViewport re-renders all messages. Use refreshStream when only the
+// in-progress reply changed.
 func (m *Model) refreshViewport() {
-	m.viewport.SetContent(renderMessages(m.messages, m.permission, m.width, m.markdownRenderer))
+	m.baseView = renderMessages(m.messages, m.permission, m.width, m.markdownRenderer, m.showThinking)
+	m.refreshStream()
+}
+
+// refreshStream redraws the viewport from the cached messages plus the reply
+// that is currently streaming in.
+func (m *Model) refreshStream() {
+	content := m.baseView
+	if m.stream.active() {
+		if len(m.messages) == 0 {
+			content = ""
+		} else {
+			content += "\n"
+		}
+		content += m.stream.render(m.width, m.markdownRenderer, m.showThinking)
+	}
+	m.viewport.SetContent(content)
 	m.viewport.GotoBottom()
 }
 
@@ -282,27 +326,112 @@ func (m *Model) dispatchNextTool() tea.Cmd {
 			return nil
 		}
 		// Start next LLM round
-		return callLLM(m.agent, m.history)
+		return m.requestLLM()
 	}
 
 	tc := m.pendingToolCalls[m.pendingToolIndex]
 
-	if m.alwaysAllow[tc.Function.Name] {
-		return executeTool(m.agent, tc)
+	if batch := m.readOnlyBatch(); len(batch) > 1 {
+		return executeToolBatch(m.turnCtx, m.agent, batch)
+	}
+	switch m.callPermission(tc) {
+	case tools.PermissionAllow:
+		return executeTool(m.turnCtx, m.agent, tc)
+	case tools.PermissionDeny:
+		return denyTool(tc, "Tool call denied by a permission rule. Do not retry it.")
 	}
 
 	// Need permission
 	m.awaitingPermission = &tc
-	m.permission = &PermissionPrompt{
-		ToolName:   tc.Function.Name,
-		Args:       tc.Function.Arguments,
-		Cursor:     0,
-		WorkingDir: m.workingDir,
-	}
+	m.permission = newPermissionPrompt(tc, m.workingDir)
 	m.refreshViewport()
 	return nil
 }
 
+// recordToolResult appends a finished tool call to history and the UI.
+func (m *Model) recordToolResult(msg ToolResultMsg) {
+	resultStr := msg.Result
+	if msg.Err != nil {
+		m.consecutiveErrors++
+		if m.consecutiveErrors >= maxConsecutiveErrors {
+			resultStr += " (Too many consecutive errors. Stop retrying and tell the user what went wrong.)"
+		}
+	} else {
+		m.consecutiveErrors = 0
+	}
+
+	// Append tool result to history
+	m.history = append(m.history, llm.Message{
+		Role:       "tool",
+		Content:    resultStr,
+		ToolCallID: msg.ToolCallID,
+	})
+
+	// Append tool call entry to UI messages
+	m.messages = append(m.messages, ChatEntry{
+		Type:    EntryToolCall,
+		Command: msg.ToolName + ": " + msg.Args,
+		Result:  msg.Result,
+		Diffs:   m.toolResultDiffs(msg),
+	})
+}
+
+// toolResultDiffs returns the changes a finished call made, from the result
+// message if the tool reported them there.
+func (m *Model) toolResultDiffs(msg ToolResultMsg) []DiffData {
+	if msg.Changes != nil {
+		return diffData(msg.Changes)
+	}
+	return parseDiffFromToolCall(msg.ToolName, msg.Args, msg.Result, m.workingDir, false)
+}
+
+// recordInterruptedTool ends the turn when a tool run was interrupted. The
+// interrupted call and every call after it get an interrupted result.
+func (m *Model) recordInterruptedTool(msg ToolResultMsg) {
+	m.messages = append(m.messages, ChatEntry{
+		Type:    EntryToolCall,
+		Command: msg.ToolName + ": " + msg.Args,
+		Result:  "Interrupted by user",
+	})
+	m.recordInterrupt()
+}
+
+// callPermission returns how a call is handled without prompting. Deny rules
+// always apply and risky shell commands always ask; after that, tools the
+// user allowed for the session run, then policy rules and finally the tool's
+// default decide. Shell commands allowed for the session are policy rules,
+// so each command in a line is still checked.
+func (m *Model) callPermission(tc llm.ToolCall) tools.Permission {
+	name := tc.Function.Name
+	decision := permission.Current.Decide(name, tc.Function.Arguments, m.workingDir)
+	switch {
+	case decision.Permission == tools.PermissionDeny:
+		return tools.PermissionDeny
+	case len(decision.Risks) > 0:
+		return tools.PermissionAsk
+	case m.alwaysAllow[name]:
+		return tools.PermissionAllow
+	case decision.Permission != "":
+		return decision.Permission
+	}
+	return tools.InfoFor(name).Permission
+}
+
+// readOnlyBatch returns the run of pending calls, starting at the next one,
+// that are read-only and already allowed. They can run concurrently because
+// none of them changes what the others see.
+func (m *Model) readOnlyBatch() []llm.ToolCall {
+	var batch []llm.ToolCall
+	for _, tc := range m.pendingToolCalls[m.pendingToolIndex:] {
+		name := tc.Function.Name
+		if m.callPermission(tc) != tools.PermissionAllow || !m.agent.IsReadOnly(name) {
+			break
+		}
+		batch = append(batch, tc)
+	}
+	return batch
+}
+
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
@@ -342,16 +471,49 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m, cmd = handleKeyMsg(m, msg)
 		return m, cmd
 
-	case StreamTokenCountMsg:
+	case StreamDeltaMsg:
+		m.retry = nil
 		m.streamingTokens = msg.Count
 		m.streamingThinking = msg.Thinking
+		m.stream.content = msg.Content
+		m.stream.reasoning = msg.Reasoning
+		if m.stream.due() {
+			m.refreshStream()
+		}
+		return m, waitForStream(msg.ch)
+
+	case RetryMsg:
+		// Drop what the failed attempt streamed; the retry starts over
+		m.retry = &msg
+		m.streamingTokens = 0
+		m.streamingThinking = false
+		m.stream.reset()
+		m.refreshStream()
 		return m, waitForStream(msg.ch)
 
 	case LLMResponseMsg:
+		m.retry = nil
 		m.streamingTokens = 0
 		m.streamingThinking = false
-		if msg.Usage != nil {
-			m.totalTokens = msg.Usage.TotalTokens
+		m.stream.reset()
+		if msg.Err == nil {
+			m.recordUsage(msg.Usage, m.promptEstimate, completionText(msg))
+		}
+		if m.interrupted() {
+			// Keep whatever streamed in before the interrupt
+			if msg.Content != "" {
+				m.history = append(m.history, llm.Message{
+					Role:    "assistant",
+					Content: msg.Content,
+				})
+				m.messages = append(m.messages, ChatEntry{
+					Type:    EntryMessage,
+					Role:    "assistant",
+					Content: msg.Content,
+				})
+			}
+			m.recordInterrupt()
+			return m, nil
 		}
 		if msg.Err != nil {
 			m.waiting = false
@@ -374,9 +536,10 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				Content: msg.Content,
 			})
 			m.messages = append(m.messages, ChatEntry{
-				Type:    EntryMessage,
-				Role:    "assistant",
-				Content: msg.Content,
+				Type:      EntryMessage,
+				Role:      "assistant",
+				Content:   msg.Content,
+				Reasoning: msg.Reasoning,
 			})
 			m.saveConversation()
 			m.refreshViewport()
@@ -391,11 +554,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		})
 
 		// If there's content alongside tool calls, show it (fixes dropped-content bug)
-		if msg.Content != "" {
+		if msg.Content != "" || msg.Reasoning != "" {
 			m.messages = append(m.messages, ChatEntry{
-				Type:    EntryMessage,
-				Role:    "assistant",
-				Content: msg.Content,
+				Type:      EntryMessage,
+				Role:      "assistant",
+				Content:   msg.Content,
+				Reasoning: msg.Reasoning,
 			})
 		}
 
@@ -409,8 +573,40 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 
 	case CompactResultMsg:
+		m.compacting = false
+		if msg.Auto {
+			if m.interrupted() {
+				m.recordInterrupt()
+				return m, nil
+			}
+			if msg.Err != nil {
+				// Carry on uncompacted; the request may still fit
+				m.messages = append(m.messages, ChatEntry{
+					Type:    EntryError,
+					Content: "Auto-compaction failed: " + msg.Err.Error(),
+				})
+			} else {
+				if msg.Usage != nil {
+					m.recordUsage(msg.Usage, 0, msg.Summary)
+				}
+				m.applyCompaction(msg.Summary, msg.Boundary, true)
+				m.saveConversation()
+			}
+			m.refreshViewport()
+			m.estimatePrompt()
+			return m, callLLM(m.turnCtx, m.provider, m.agent, m.history)
+		}
+
 		m.waiting = false
 		m.textarea.Focus()
+		if m.interrupted() {
+			m.messages = append(m.messages, ChatEntry{
+				Type:    EntryNotice,
+				Content: "Compact interrupted by user",
+			})
+			m.refreshViewport()
+			return m, nil
+		}
 		if msg.Err != nil {
 			m.messages = append(m.messages, ChatEntry{
 				Type:    EntryError,
@@ -419,6 +615,9 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.refreshViewport()
 			return m, nil
 		}
+		if msg.Usage != nil {
+			m.recordUsage(msg.Usage, 0, msg.Summary)
+		}
 		m.history = []llm.Message{
 			{
 				Role:    "user",
@@ -432,46 +631,42 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				Content: "Conversation compacted:\n\n" + msg.Summary,
 			},
 		}
-		if msg.Usage != nil {
-			m.totalTokens = msg.Usage.TotalTokens
-		}
+		m.conv.Compactions = append(m.conv.Compactions, conversation.Compaction{
+			Time:               time.Now(),
+			SummarizedMessages: msg.Boundary,
+			TokensBefore:       m.totalTokens,
+		})
+		m.estimatePrompt()
 		m.saveConversation()
 		m.refreshViewport()
 		return m, nil
 
 	case ToolResultMsg:
-		command := msg.ToolName + ": " + msg.Args
-		resultStr := msg.Result
-
-		if msg.Err != nil {
-			m.consecutiveErrors++
-			if m.consecutiveErrors >= maxConsecutiveErrors {
-				resultStr += " (Too many consecutive errors. Stop retrying and tell the user what went wrong.)"
-			}
-		} else {
-			m.consecutiveErrors = 0
+		if m.interrupted() {
+			m.recordInterruptedTool(msg)
+			return m, nil
 		}
+		m.recordToolResult(msg)
+		m.saveConversation()
+		m.refreshViewport()
 
-		// Append tool result to history
-		m.history = append(m.history, llm.Message{
-			Role:       "tool",
-			Content:    resultStr,
-			ToolCallID: msg.ToolCallID,
-		})
+		// Advance to next tool
+		m.pendingToolIndex++
+		cmd := m.dispatchNextTool()
+		return m, cmd
 
-		// Append tool call entry to UI messages
-		entry := ChatEntry{
-			Type:    EntryToolCall,
-			Command: command,
-			Result:  msg.Result,
-			Diff:    parseDiffFromToolCall(msg.ToolName, msg.Args, msg.Result, m.workingDir, false),
+	case ToolBatchResultMsg:
+		if m.interrupted() {
+			m.recordInterruptedTool(msg.Results[0])
+			return m, nil
+		}
+		for _, r := range msg.Results {
+			m.recordToolResult(r)
 		}
-		m.messages = append(m.messages, entry)
 		m.saveConversation()
 		m.refreshViewport()
 
-		// Advance to next tool
-		m.pendingToolIndex++
+		m.pendingToolIndex += len(msg.Results)
 		cmd := m.dispatchNextTool()
 		return m, cmd
 
@@ -532,20 +727,36 @@ func (m *Model) View() string {
 func (m *Model) renderStatusLine() string {
 	// Left: thinking/streaming indicator
 	var left string
-	if m.waiting {
+	if m.waiting && m.interrupted() {
+		left = m.spinner.View() + " Interrupting…"
+	} else if m.waiting && m.compacting {
+		left = m.spinner.View() + " Compacting conversation… · esc to interrupt"
+	} else if m.waiting && m.retry != nil {
+		status := "Retrying"
+		if remaining := time.Until(m.retry.Until); remaining > 0 {
+			status = fmt.Sprintf("Retrying in %ds", int(remaining.Round(time.Second).Seconds()))
+		}
+		left = m.spinner.View() + fmt.Sprintf(" %s (attempt %d/%d) · %s · esc to interrupt",
+			status, m.retry.Attempt, m.retry.MaxAttempts, m.retry.Reason)
+	} else if m.waiting {
 		if m.streamingTokens > 0 {
 			thinkingStr := ""
 			if m.streamingThinking {
 				thinkingStr = " · ( thinking )"
 			}
-			left = m.spinner.View() + fmt.Sprintf(" Processing · ⬇ %d tokens%s", m.streamingTokens, thinkingStr)
+			left = m.spinner.View() + fmt.Sprintf(" Processing · ⬇ %d tokens%s · esc to interrupt", m.streamingTokens, thinkingStr)
 		} else {
-			left = m.spinner.View() + " Processing"
+			left = m.spinner.View() + " Processing · esc to interrupt"
 		}
 	}
 
-	// Right: <token label> <bar>
-	tokenLabel := statusStyle.Render(fmt.Sprintf("%d/%d ", m.totalTokens, config.MaxContextTokens))
+	// Right: <shell dir> <cost> <token label> <bar>
+	label := fmt.Sprintf("%d/%d ", m.totalTokens, m.contextWindow())
+	if m.conv.Usage.CostUSD > 0 {
+		label = fmt.Sprintf("$%.2f · %s", m.conv.Usage.CostUSD, label)
+	}
+	label = shortenPath(tools.ShellDir(m.workingDir)) + " · " + label
+	tokenLabel := statusStyle.Render(label)
 	barMaxWidth := m.width * 40 / 100
 	if barMaxWidth < 1 {
 		barMaxWidth = 1
@@ -554,7 +765,7 @@ func (m *Model) renderStatusLine() string {
 	if displayTokens < 1000 {
 		displayTokens = 1000
 	}
-	bar := renderBar(displayTokens, config.MaxContextTokens, barMaxWidth)
+	bar := renderBar(displayTokens, m.contextWindow(), barMaxWidth)
 	right := tokenLabel + bar + "  "
 
 	// Layout: <left> <gap> <right>
@@ -567,6 +778,21 @@ func (m *Model) renderStatusLine() string {
 	return left + strings.Repeat(" ", gap) + right
 }
 
+// shortenPath abbreviates the home directory in path as ~.
+func shortenPath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return "~" + string(filepath.Separator) + rest
+	}
+	return path
+}
+
 func renderBar(value, max, width int) string {
 	ratio := float64(value) / float64(max)
 	if ratio > 1 {
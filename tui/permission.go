@@ -2,8 +2,13 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 
+	"go-tui/agent/tools"
 	"go-tui/config"
+	"go-tui/llm"
+	"go-tui/permission"
+	"go-tui/sandbox"
 )
 
 // Styles are defined in theme.go
@@ -13,6 +18,79 @@ type PermissionPrompt struct {
 	Args       string
 	Cursor     int
 	WorkingDir string
+	Options    []permOption
+	Warnings   []string
+	Sandbox    string // how the command will be confined, for tools that run commands
+}
+
+// permChoice is what the user picked in the permission prompt.
+type permChoice int
+
+const (
+	choiceAllow        permChoice = iota // run this call
+	choiceAllowRule                      // save Rule to the project policy and run
+	choiceAllowSession                   // allow the tool, or for shell tools Rules, until exit and run
+	choiceDeny                           // refuse and end the turn
+)
+
+type permOption struct {
+	Label  string
+	Choice permChoice
+	Rules  []permission.Rule
+}
+
+// newPermissionPrompt builds the prompt for tc. Besides allowing or denying
+// the call once, it offers to save rules for this exact call and broader
+// prefix rules, when the tool supports rules, and to allow the tool for the
+// session. For a shell tool the session option only allows the commands of
+// this call, as allowing the tool would let any command line through the
+// checks made on each of its commands. Risks found in a shell command
+// are shown as warnings, and tools that run commands say whether they will be
+// sandboxed.
+func newPermissionPrompt(tc llm.ToolCall, workingDir string) *PermissionPrompt {
+	name, args := tc.Function.Name, tc.Function.Arguments
+	options := []permOption{{Label: "Allow", Choice: choiceAllow}}
+	exact, prefix := permission.Current.SuggestRules(name, args, workingDir)
+	for _, rules := range [][]permission.Rule{exact, prefix} {
+		if len(rules) == 0 {
+			continue
+		}
+		options = append(options, permOption{
+			Label:  "Always allow " + ruleLabels(rules),
+			Choice: choiceAllowRule,
+			Rules:  rules,
+		})
+	}
+	switch {
+	case tools.InfoFor(name).RuleKind != tools.RuleShell:
+		options = append(options, permOption{Label: fmt.Sprintf("Allow %s for this session", name), Choice: choiceAllowSession})
+	case len(exact) > 0:
+		options = append(options, permOption{
+			Label:  fmt.Sprintf("Allow %s for this session", ruleLabels(exact)),
+			Choice: choiceAllowSession,
+			Rules:  exact,
+		})
+	}
+	options = append(options, permOption{Label: "Deny", Choice: choiceDeny})
+	p := &PermissionPrompt{
+		ToolName:   name,
+		Args:       args,
+		WorkingDir: workingDir,
+		Options:    options,
+		Warnings:   permission.Current.Decide(name, args, workingDir).Risks,
+	}
+	if tools.InfoFor(name).Sandboxed {
+		p.Sandbox = sandbox.Describe()
+	}
+	return p
+}
+
+func ruleLabels(rules []permission.Rule) string {
+	labels := make([]string, len(rules))
+	for i, r := range rules {
+		labels[i] = r.String()
+	}
+	return strings.Join(labels, ", ")
 }
 
 func (p PermissionPrompt) View(width int) string {
@@ -34,24 +112,29 @@ func (p PermissionPrompt) View(width int) string {
 
 	title := permTitleStyle.Render("Tool Permission Required")
 
-	options := []string{
-		"Allow",
-		fmt.Sprintf("Always Allow %s", p.ToolName),
-		"Deny",
-	}
-
 	var optionsView string
-	for i, opt := range options {
+	for i, opt := range p.Options {
 		cursor := "  "
 		style := permOptionStyle
 		if i == p.Cursor {
 			cursor = "> "
 			style = permSelectedStyle
 		}
-		optionsView += cursor + style.Render(opt) + "\n"
+		optionsView += cursor + style.Render(opt.Label) + "\n"
+	}
+
+	var warnings string
+	for _, w := range p.Warnings {
+		warnings += permWarningStyle.Render("⚠ "+w) + "\n"
+	}
+	if p.Sandbox != "" {
+		warnings += permOptionStyle.Render(p.Sandbox) + "\n"
+	}
+	if warnings != "" {
+		warnings = "\n" + warnings
 	}
 
-	content := fmt.Sprintf("%s\n\n%s", title, optionsView)
+	content := fmt.Sprintf("%s\n%s\n%s", title, warnings, optionsView)
 	permBox := permBoxStyle.Width(boxWidth).Render(content)
 
 	return toolSection + "\n" + permBox
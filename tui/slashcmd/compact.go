@@ -1,5 +1,5 @@
 package slashcmd
 
 func init() {
-	Register(Command{"/compact", "Summarize and compact conversation history"})
+	Register(Command{"/compact", "Summarize and compact conversation history (optional: focus)"})
 }
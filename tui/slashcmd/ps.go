@@ -0,0 +1,5 @@
+package slashcmd
+
+func init() {
+	Register(Command{"/ps", "List background processes"})
+}
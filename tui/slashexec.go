@@ -1,8 +1,11 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
+	"go-tui/agent/tools"
 	"go-tui/tui/slashcmd"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,29 +14,37 @@ import (
 // executeSlashCommand checks if text is a known slash command and executes it.
 // Returns (true, cmd) if the text was handled as a command, (false, nil) otherwise.
 func (m *Model) executeSlashCommand(text string) (bool, tea.Cmd) {
+	if focus, ok := strings.CutPrefix(text, "/compact "); ok {
+		return m.executeCompact(strings.TrimSpace(focus))
+	}
 	switch text {
 	case "/clear":
 		m.messages = nil
 		m.history = nil
 		m.totalTokens = 0
+		tools.ResetShell()
 		m.saveConversation()
 		m.refreshViewport()
 		return true, nil
 	case "/compact":
-		if len(m.history) == 0 {
-			m.messages = append(m.messages, ChatEntry{
-				Type:    EntryError,
-				Content: "Nothing to compact",
-			})
-			m.refreshViewport()
-			return true, nil
-		}
-		m.waiting = true
-		m.textarea.Blur()
-		return true, compactHistory(m.history)
+		return m.executeCompact("")
 	case "/rewind":
 		return m.executeRewind()
-	case "/help", "/status":
+	case "/ps":
+		m.messages = append(m.messages, ChatEntry{
+			Type:    EntryNotice,
+			Content: processReport(tools.Processes()),
+		})
+		m.refreshViewport()
+		return true, nil
+	case "/status":
+		m.messages = append(m.messages, ChatEntry{
+			Type:    EntryNotice,
+			Content: m.statusReport(),
+		})
+		m.refreshViewport()
+		return true, nil
+	case "/help":
 		m.messages = append(m.messages, ChatEntry{
 			Type:    EntryError,
 			Content: "Command not yet implemented",
@@ -45,6 +56,23 @@ func (m *Model) executeSlashCommand(text string) (bool, tea.Cmd) {
 	}
 }
 
+// executeCompact starts summarizing the whole history. focus is the optional
+// text after "/compact", telling the summarizer what matters most.
+func (m *Model) executeCompact(focus string) (bool, tea.Cmd) {
+	if len(m.history) == 0 {
+		m.messages = append(m.messages, ChatEntry{
+			Type:    EntryError,
+			Content: "Nothing to compact",
+		})
+		m.refreshViewport()
+		return true, nil
+	}
+	m.waiting = true
+	m.compacting = true
+	m.textarea.Blur()
+	return true, compactHistory(m.startTurn(), m.provider, m.history, focus)
+}
+
 func (m *Model) executeRewind() (bool, tea.Cmd) {
 	var items []slashcmd.RewindItem
 	historyPos := 0
@@ -95,3 +123,15 @@ func (m *Model) executeRewind() (bool, tea.Cmd) {
 	}
 	return true, nil
 }
+
+// processReport lists the background processes started this session.
+func processReport(procs []tools.ProcessInfo) string {
+	if len(procs) == 0 {
+		return "No background processes"
+	}
+	lines := []string{"Background processes"}
+	for _, p := range procs {
+		lines = append(lines, fmt.Sprintf("%-5s %-20s %s ago  %s", p.ID, p.Status, time.Since(p.Started).Round(time.Second), strings.ReplaceAll(p.Command, "\n", " ")))
+	}
+	return strings.Join(lines, "\n")
+}
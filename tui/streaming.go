@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-tui/config"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// streamView holds the assistant reply that is still arriving. Glamour is
+// too slow to re-render a long answer on every delta, so the content is split
+// into a stable prefix of finished paragraphs, rendered as markdown once per
+// change, and an unfinished tail that is shown as plain wrapped text.
+type streamView struct {
+	content   string
+	reasoning string
+
+	stable         string // markdown source of the rendered prefix
+	stableRendered string
+	lastRender     time.Time
+}
+
+// active reports whether there is anything streamed to show.
+func (s *streamView) active() bool {
+	return s.content != "" || s.reasoning != ""
+}
+
+func (s *streamView) reset() {
+	*s = streamView{}
+}
+
+// due reports whether enough time has passed since the last render.
+func (s *streamView) due() bool {
+	return time.Since(s.lastRender) >= config.StreamRenderInterval
+}
+
+// render returns the streamed reply for display below the finished messages.
+func (s *streamView) render(width int, md *MarkdownRenderer, showThinking bool) string {
+	s.lastRender = time.Now()
+
+	var parts []string
+	if s.reasoning != "" {
+		parts = append(parts, renderThinking(s.reasoning, showThinking, s.content == "", width))
+	}
+
+	if s.content != "" {
+		stable, tail := splitStableMarkdown(s.content)
+		if stable != s.stable {
+			s.stable = stable
+			s.stableRendered = stable
+			if md != nil && isMarkdown(stable) {
+				if r, err := md.Render(stable); err == nil {
+					s.stableRendered = r
+				}
+			}
+		}
+
+		var body []string
+		if s.stableRendered != "" {
+			body = append(body, strings.Trim(s.stableRendered, "\n"))
+		}
+		if tail = strings.TrimSpace(tail); tail != "" {
+			if width > 0 {
+				tail = lipgloss.NewStyle().Width(width).Render(tail)
+			}
+			body = append(body, tail)
+		}
+		parts = append(parts, strings.Join(body, "\n\n"))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// splitStableMarkdown splits streamed markdown at the last paragraph break
+// that is not inside a fenced code block. Everything before the break will
+// not change as more text arrives, so it can be rendered and cached.
+func splitStableMarkdown(content string) (stable, tail string) {
+	cut := -1
+	inFence := false
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		offset += len(line)
+		if !inFence && trimmed == "" && strings.HasSuffix(line, "\n") && offset < len(content) {
+			cut = offset
+		}
+	}
+	if cut < 0 {
+		return "", content
+	}
+	return content[:cut], content[cut:]
+}
+
+// renderThinking renders reasoning content as a collapsible block. Collapsed,
+// it is a single summary line; expanded, the full text is shown dimmed.
+func renderThinking(reasoning string, expanded, inProgress bool, width int) string {
+	label := "✻ Thought"
+	if inProgress {
+		label = "✻ Thinking…"
+	}
+	words := len(strings.Fields(reasoning))
+	if !expanded {
+		return thinkingStyle.Render(fmt.Sprintf("%s (%d words) · ctrl+o to expand", label, words))
+	}
+	header := thinkingStyle.Render(fmt.Sprintf("%s · ctrl+o to collapse", label))
+	style := thinkingStyle
+	if width > 2 {
+		style = style.Width(width - 2)
+	}
+	return header + "\n" + indentBlock(style.Render(strings.TrimSpace(reasoning)))
+}
@@ -47,6 +47,14 @@ var (
 			Foreground(colorRust).
 			Bold(true)
 
+	noticeStyle = lipgloss.NewStyle().
+			Foreground(colorDimBrass).
+			Italic(true)
+
+	thinkingStyle = lipgloss.NewStyle().
+			Foreground(colorForgedIron).
+			Italic(true)
+
 	// Diffs
 	diffAddedStyle = lipgloss.NewStyle().
 			Foreground(colorPatina)
@@ -88,6 +96,19 @@ var (
 				Foreground(colorAmber).
 				Bold(true)
 
+	permWarningStyle = lipgloss.NewStyle().
+				Foreground(colorRust).
+				Bold(true)
+
+	// Exit badges of bash results
+	exitOKStyle = lipgloss.NewStyle().
+			Foreground(colorPatina).
+			Bold(true)
+
+	exitFailStyle = lipgloss.NewStyle().
+			Foreground(colorRust).
+			Bold(true)
+
 )
 
 // tokenBarColor returns the appropriate color for the token usage bar.
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"go-tui/config"
+	"go-tui/llm"
+)
+
+// estimatePrompt estimates the prompt size of the next LLM request and shows
+// it in the context bar until the provider reports real usage. The estimate is
+// scaled by how far off previous estimates were for this provider.
+func (m *Model) estimatePrompt() {
+	messages := append([]llm.Message{{Role: "system", Content: m.agent.SystemPrompt()}}, m.history...)
+	m.promptEstimate = llm.EstimatePromptTokens(m.provider.Model(), messages, m.agent.Tools())
+	m.totalTokens = m.scaledEstimate(m.promptEstimate)
+}
+
+func (m *Model) scaledEstimate(tokens int) int {
+	if m.tokenScale <= 0 {
+		return tokens
+	}
+	return int(float64(tokens) * m.tokenScale)
+}
+
+// recordUsage adds one request to the conversation's usage totals. Providers
+// that report no usage are accounted with estimates instead. promptEstimate is
+// the unscaled estimate for the request, used to calibrate future estimates;
+// pass 0 for side requests such as compaction, which also leave the context
+// bar alone.
+func (m *Model) recordUsage(usage *llm.Usage, promptEstimate int, completion string) {
+	prompt, completionTokens := 0, 0
+	if usage != nil {
+		prompt, completionTokens = usage.PromptTokens, usage.CompletionTokens
+		if promptEstimate > 0 && usage.PromptTokens > 0 {
+			m.tokenScale = float64(usage.PromptTokens) / float64(promptEstimate)
+		}
+	} else {
+		prompt = m.scaledEstimate(promptEstimate)
+		completionTokens = m.scaledEstimate(llm.EstimateTokens(m.provider.Model(), completion))
+	}
+	if promptEstimate > 0 {
+		m.totalTokens = prompt + completionTokens
+	}
+
+	var cost float64
+	if price, ok := config.Current.PriceFor(m.provider.Model()); ok {
+		cost = price.Cost(prompt, completionTokens)
+	}
+	m.conv.Usage.Add(prompt, completionTokens, cost)
+}
+
+// completionText returns everything the model generated in a response, for
+// estimating completion tokens.
+func completionText(msg LLMResponseMsg) string {
+	var sb strings.Builder
+	sb.WriteString(msg.Reasoning)
+	sb.WriteString(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		sb.WriteString(tc.Function.Name)
+		sb.WriteString(tc.Function.Arguments)
+	}
+	return sb.String()
+}
+
+// statusReport renders the /status summary.
+func (m *Model) statusReport() string {
+	u := m.conv.Usage
+	window := m.contextWindow()
+	pct := 100 * m.totalTokens / window
+
+	lines := []string{
+		fmt.Sprintf("Conversation  %s", m.conv.ID),
+		fmt.Sprintf("Model         %s / %s", m.provider.Name(), m.provider.Model()),
+		fmt.Sprintf("Context       %s / %s tokens (%d%%)", formatCount(m.totalTokens), formatCount(window), pct),
+		fmt.Sprintf("Session       %d requests · %s prompt · %s completion tokens",
+			u.Requests, formatCount(u.PromptTokens), formatCount(u.CompletionTokens)),
+	}
+	if _, ok := config.Current.PriceFor(m.provider.Model()); ok {
+		lines = append(lines, fmt.Sprintf("Cost          $%.4f", u.CostUSD))
+	} else {
+		lines = append(lines, fmt.Sprintf("Cost          $%.4f (no price configured for %s)", u.CostUSD, m.provider.Model()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCount formats n with thousands separators.
+func formatCount(n int) string {
+	s := fmt.Sprintf("%d", n)
+	if n < 0 {
+		return s
+	}
+	var out []byte
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}